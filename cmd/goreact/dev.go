@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/daodao97/goreact/server"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// 为空时先 go build 再运行产物, 便于重启时直接结束应用进程
+	defaultAppCommand = ""
+	// 应用停止的等待时间, 超时后强制结束
+	appStopTimeout = 5 * time.Second
+	// Go 文件变动的防抖时间
+	restartDebounce = 300 * time.Millisecond
+)
+
+// dev 模式下按名称不监听的目录
+var devIgnoredNames = []string{".git", "node_modules"}
+
+// devIgnoredDirs 不监听的目录, 构建产物和 metafile 由构建生成, frontend 和 locales 由应用内的 HMR 监听
+func devIgnoredDirs(config *server.BuildConfig) []string {
+	locales, _ := filepath.Abs("locales")
+	return []string{config.BuildDir, config.TmpFrontendDir, config.FrontendDir, config.MetaDir, locales}
+}
+
+func runDev(args []string) error {
+	fs := newFlagSet("dev", "[--config goreact.yaml] [--force] [--mode development|production] [--pkg .] [--cmd \"...\"]")
+	configPath := configFlag(fs)
+	force := fs.Bool("force", false, "忽略 hash 缓存, 强制重新构建")
+	mode := fs.String("mode", string(server.ModeDevelopment), "构建模式: development 或 production")
+	pkg := fs.String("pkg", ".", "应用的 main 包")
+	command := fs.String("cmd", defaultAppCommand, "启动应用的命令, 为空时编译 --pkg 后运行")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := applyConfig(fs, *configPath, *mode); err != nil {
+		return err
+	}
+
+	// 构建失败不退出, 修复前端代码后由应用内的 HMR 重新构建
+	if err := server.BuildJSWithForce(*force); err != nil {
+		fmt.Fprintf(os.Stderr, "goreact dev: build failed: %v\n", err)
+	}
+
+	app := newAppProcess(*pkg, *command)
+
+	watcher, err := newGoWatcher(".", devIgnoredDirs(server.GetBuildConfig()))
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := app.start(); err != nil {
+		fmt.Fprintf(os.Stderr, "goreact dev: %v\n", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-signals:
+			return app.stop()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return app.stop()
+			}
+			watcher.handle(event)
+			if isGoSource(event.Name) {
+				debounce = time.After(restartDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if ok {
+				fmt.Fprintf(os.Stderr, "goreact dev: watch error: %v\n", err)
+			}
+		case <-debounce:
+			debounce = nil
+			fmt.Fprintln(os.Stderr, "goreact dev: go files changed, restarting")
+			if err := app.stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "goreact dev: %v\n", err)
+			}
+			if err := app.start(); err != nil {
+				fmt.Fprintf(os.Stderr, "goreact dev: %v\n", err)
+			}
+		}
+	}
+}
+
+func isGoSource(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasSuffix(base, ".go") || base == "go.mod" || base == "go.sum"
+}
+
+// appProcess 应用进程
+type appProcess struct {
+	pkg     string
+	command []string
+	env     []string
+
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func newAppProcess(pkg, command string, env ...string) *appProcess {
+	return &appProcess{pkg: pkg, command: strings.Fields(command), env: env}
+}
+
+// binaryPath 编译产物路径
+func (a *appProcess) binaryPath() string {
+	pwd, _ := os.Getwd()
+	name := "goreact-app-" + filepath.Base(pwd)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// start 启动应用, 未指定命令时先编译
+func (a *appProcess) start() error {
+	command := a.command
+	if len(command) == 0 {
+		binary := a.binaryPath()
+		build := exec.Command("go", "build", "-o", binary, a.pkg)
+		build.Stdout = os.Stdout
+		build.Stderr = os.Stderr
+		if err := build.Run(); err != nil {
+			return fmt.Errorf("go build %s failed: %w", a.pkg, err)
+		}
+		command = []string{binary}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), a.env...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s failed: %w", strings.Join(command, " "), err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	a.cmd = cmd
+	a.done = done
+	return nil
+}
+
+// stop 结束应用, 先发送中断信号, 超时后强制结束
+func (a *appProcess) stop() error {
+	if a.cmd == nil {
+		return nil
+	}
+	defer func() {
+		a.cmd = nil
+		a.done = nil
+	}()
+
+	select {
+	case <-a.done:
+		// 已经退出
+		return nil
+	default:
+	}
+
+	if runtime.GOOS == "windows" || a.cmd.Process.Signal(os.Interrupt) != nil {
+		a.cmd.Process.Kill()
+	}
+
+	select {
+	case <-a.done:
+	case <-time.After(appStopTimeout):
+		a.cmd.Process.Kill()
+		<-a.done
+	}
+	return nil
+}
+
+// runUntilSignal 运行应用直到退出或收到中断信号, 应用异常退出时返回错误
+func (a *appProcess) runUntilSignal() error {
+	if err := a.start(); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case <-signals:
+		return a.stop()
+	case err := <-a.done:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("app exited with code %d", exitErr.ExitCode())
+		}
+		return err
+	}
+}
+
+// goWatcher 递归监听项目中的 Go 文件
+type goWatcher struct {
+	*fsnotify.Watcher
+	// 不监听的目录, 绝对路径
+	ignoredDirs []string
+}
+
+func newGoWatcher(root string, ignoredDirs []string) (*goWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &goWatcher{Watcher: watcher, ignoredDirs: ignoredDirs}
+	if err := w.addTree(root); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *goWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && w.ignored(path) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// ignored 是否是不监听的目录, 隐藏目录都不监听
+func (w *goWatcher) ignored(path string) bool {
+	name := filepath.Base(path)
+	if slices.Contains(devIgnoredNames, name) || strings.HasPrefix(name, ".") {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	return err == nil && slices.Contains(w.ignoredDirs, abs)
+}
+
+// handle 新建的目录加入监听
+func (w *goWatcher) handle(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) {
+		return
+	}
+	if w.ignored(event.Name) {
+		return
+	}
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		if err := w.addTree(event.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "goreact dev: watch %s failed: %v\n", event.Name, err)
+		}
+	}
+}
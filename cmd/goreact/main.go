@@ -1,9 +1,341 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/daodao97/goreact/server"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
 )
 
+const usage = `goreact - Go + React SSR 工具
+
+用法:
+  goreact <command> [flags]
+
+命令:
+  build    构建前端资源, --analyze 输出产物分析报告
+  dev      构建并启动应用, 前端由应用内 HMR 监听, Go 文件变动时重启应用
+  start    以生产模式启动应用, 要求已经执行过 build
+  routes   列出文件路由注册的页面路由、组件及其客户端入口, 要求已经执行过 build
+  clean    删除 build 目录、临时前端目录、metafile 目录和当前项目的构建 hash 缓存
+
+使用 "goreact <command> -h" 查看命令参数
+`
+
+// errUsage 参数错误, 以退出码 2 退出
+var errUsage = errors.New("usage error")
+
+type command struct {
+	name string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{name: "build", run: runBuild},
+	{name: "dev", run: runDev},
+	{name: "start", run: runStart},
+	{name: "routes", run: runRoutes},
+	{name: "clean", run: runClean},
+}
+
 func main() {
-	server.BuildJS()
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	name := args[0]
+	if name == "-h" || name == "--help" || name == "help" {
+		fmt.Fprint(os.Stdout, usage)
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.Is(err, errUsage):
+			return exitUsage
+		default:
+			fmt.Fprintf(os.Stderr, "goreact %s: %v\n", name, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "goreact: unknown command %q\n\n%s", name, usage)
+	return exitUsage
+}
+
+// newFlagSet 创建子命令参数解析器, 解析失败时返回 errUsage
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet("goreact "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: goreact %s %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// configFlag 添加 --config 参数
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "构建配置文件, 默认使用当前目录中的 "+server.ConfigFileName+" (存在时)")
+}
+
+// applyConfig 加载构建配置并设置构建模式
+// 配置文件中的 mode 优先于命令的默认模式, 显式传入的 --mode 优先于配置文件
+func applyConfig(fs *flag.FlagSet, configPath, mode string) error {
+	loaded, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if mode == "" || (loaded && !isFlagSet(fs, "mode")) {
+		return nil
+	}
+	return setMode(fs, mode)
+}
+
+// loadConfig 加载构建配置, 没有指定且当前目录没有配置文件时使用默认配置
+// 配置文件的路径通过 GOREACT_CONFIG 传给 dev/start 启动的应用
+func loadConfig(configPath string) (bool, error) {
+	if configPath == "" {
+		if _, err := os.Stat(server.ConfigFileName); err != nil {
+			return false, nil
+		}
+		configPath = server.ConfigFileName
+	}
+
+	configPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return false, err
+	}
+
+	config, err := server.LoadBuildConfig(configPath)
+	if err != nil {
+		return false, err
+	}
+	if err := server.SetBuildConfig(config); err != nil {
+		return false, err
+	}
+	return true, os.Setenv(server.ConfigEnv, configPath)
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// setMode 解析 --mode 参数并设置构建模式
+func setMode(fs *flag.FlagSet, mode string) error {
+	buildMode, err := server.ParseBuildMode(mode)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return errUsage
+	}
+	server.SetBuildMode(buildMode)
+	// dev 启动的应用按同一模式构建
+	os.Setenv(server.ModeEnv, string(buildMode))
+	return nil
+}
+
+func runBuild(args []string) error {
+	fs := newFlagSet("build", "[--config goreact.yaml] [--force] [--mode production|development] [--analyze] [--typecheck] [--typecheck-fatal]")
+	configPath := configFlag(fs)
+	force := fs.Bool("force", false, "忽略 hash 缓存, 强制重新构建")
+	mode := fs.String("mode", string(server.ModeProduction), "构建模式: production 或 development")
+	analyze := fs.Bool("analyze", false, "构建后输出产物分析报告, 同时写入文本和 HTML 报告")
+	typecheck := fs.Bool("typecheck", false, "与打包并行执行 tsc --noEmit 并输出类型错误")
+	typecheckFatal := fs.Bool("typecheck-fatal", false, "生产模式下有类型错误时构建失败, 包含 --typecheck")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := applyConfig(fs, *configPath, *mode); err != nil {
+		return err
+	}
+	if *typecheck || *typecheckFatal {
+		server.SetTypeCheck(*typecheckFatal || server.GetBuildConfig().TypeCheckFatal)
+	}
+
+	config := server.GetBuildConfig()
+	builder := server.NewJSBuilder(config)
+	err := builder.Build(*force)
+	if result := builder.TypeCheck(); result != nil {
+		if err := result.WriteText(os.Stdout); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !*analyze {
+		return nil
+	}
+
+	report, err := server.AnalyzeBuild(config)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	htmlPath, err := report.SaveReports(config)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nreport: %s\n", displayPath(htmlPath))
+	return nil
+}
+
+func runStart(args []string) error {
+	fs := newFlagSet("start", "[--config goreact.yaml] [--pkg .] [--cmd \"...\"]")
+	configPath := configFlag(fs)
+	pkg := fs.String("pkg", ".", "应用的 main 包")
+	command := fs.String("cmd", defaultAppCommand, "启动应用的命令, 为空时编译 --pkg 后运行")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := applyConfig(fs, *configPath, ""); err != nil {
+		return err
+	}
+
+	manifest := filepath.Join(server.GetBuildConfig().BuildDir, server.ManifestFileName)
+	if _, err := os.Stat(manifest); err != nil {
+		return fmt.Errorf("%s not found, run \"goreact build\" first", manifest)
+	}
+
+	return newAppProcess(*pkg, *command, "GIN_MODE=release").runUntilSignal()
+}
+
+func runRoutes(args []string) error {
+	fs := newFlagSet("routes", "[--config goreact.yaml]")
+	configPath := configFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := applyConfig(fs, *configPath, ""); err != nil {
+		return err
+	}
+	pagesDir := displayPath(filepath.Join(server.GetBuildConfig().FrontendDir, "pages"))
+
+	manifest := filepath.Join(server.GetBuildConfig().BuildDir, server.ManifestFileName)
+	if _, err := os.Stat(manifest); err != nil {
+		return fmt.Errorf("%s not found, run \"goreact build\" first", manifest)
+	}
+
+	pages, err := server.Pages()
+	if err != nil {
+		return err
+	}
+	assets := map[string]string{}
+	for _, page := range pages {
+		assets[page.Component] = page.Asset
+	}
+
+	// 注册到临时的 gin 实例, 列出的路由与应用中 NewFSRouter().Register 的结果一致
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(nil); err != nil {
+		return err
+	}
+	router := server.NewFSRouter()
+	if err := router.Register(engine); err != nil {
+		return err
+	}
+	routes, err := router.Routes()
+	if err != nil {
+		return err
+	}
+	registered := map[string]bool{}
+	for _, info := range engine.Routes() {
+		if info.Method == http.MethodGet {
+			registered[info.Path] = true
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tCOMPONENT\tFILE\tASSET")
+	for _, route := range routes {
+		for _, p := range router.Paths(route) {
+			if registered[p] {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p, route.Component, path.Join(pagesDir, route.File), orDash(assets[route.Component]))
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// displayPath 当前目录中的路径显示为相对路径
+func displayPath(p string) string {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	if rel, err := filepath.Rel(pwd, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(p)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runClean(args []string) error {
+	fs := newFlagSet("clean", "[--config goreact.yaml]")
+	configPath := configFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := applyConfig(fs, *configPath, ""); err != nil {
+		return err
+	}
+
+	return server.Clean()
 }
@@ -3,6 +3,7 @@ module github.com/daodao97/goreact
 go 1.24.0
 
 require (
+	github.com/andybalholm/brotli v1.0.5
 	github.com/daodao97/xgo v0.0.0-20250730041808-2db993900929
 	github.com/evanw/esbuild v0.25.4
 	github.com/fsnotify/fsnotify v1.7.0
@@ -14,12 +15,13 @@ require (
 	github.com/sendgrid/sendgrid-go v3.16.0+incompatible
 	github.com/spf13/cast v1.6.0
 	github.com/tidwall/gjson v1.18.0
+	golang.org/x/sys v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 	rogchap.com/v8go v0.9.0
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/andybalholm/brotli v1.0.5 // indirect
 	github.com/avast/retry-go v3.0.0+incompatible // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/bytedance/sonic v1.13.2 // indirect
@@ -65,10 +67,8 @@ require (
 	golang.org/x/arch v0.15.0 // indirect
 	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/net v0.38.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	muzzammil.xyz/jsonc v1.0.0 // indirect
 )
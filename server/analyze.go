@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/daodao97/xgo/xlog"
+)
+
+const (
+	// 客户端和服务端的 esbuild metafile, 位于 MetaDir 下
+	clientMetafileName = "client.meta.json"
+	serverMetafileName = "server.meta.json"
+
+	// 分析报告文件名, 位于 MetaDir 下
+	ReportTextFileName = "report.txt"
+	ReportHTMLFileName = "report.html"
+
+	// 报告中列出的最大模块数
+	reportTopModules = 20
+)
+
+//go:embed templates/analyze/report.html
+var analyzeTemplate string
+
+// BundleReport 构建产物分析报告, 大小单位为字节
+type BundleReport struct {
+	Pages        []PageSize
+	SharedChunks []ChunkSize
+	TopModules   []ModuleSize
+	Server       []AssetSize
+}
+
+// AssetSize 单个产物文件的大小
+type AssetSize struct {
+	// 相对于 BuildDir 的路径
+	File  string
+	Bytes int
+	Gzip  int
+}
+
+// PageSize 页面首次加载需要的 JS 和 CSS
+type PageSize struct {
+	// 入口名称, 例如 app、app/Home、app/blog/Index
+	Name  string
+	Entry AssetSize
+	// 静态导入的 chunk 和 CSS
+	Chunks []AssetSize
+	CSS    []AssetSize
+	// 入口、chunk 和 CSS 的总大小
+	TotalBytes int
+	TotalGzip  int
+	// 适用的体积预算 (gzip), 0 表示没有预算
+	Budget int64
+}
+
+// OverBudget 是否超出预算
+func (p PageSize) OverBudget() bool {
+	return p.Budget > 0 && int64(p.TotalGzip) > p.Budget
+}
+
+// ChunkSize 被多个页面共享的 chunk
+type ChunkSize struct {
+	AssetSize
+	Pages []string
+}
+
+// ModuleSize 源码模块打包后在客户端产物中的大小
+type ModuleSize struct {
+	// 相对于 RootDir 的路径, 例如 node_modules/react-dom/cjs/react-dom.production.js
+	Path  string
+	Bytes int
+}
+
+// AnalyzeBuild 根据上一次构建的 metafile 生成分析报告
+func AnalyzeBuild(config *BuildConfig) (*BundleReport, error) {
+	clientMeta, err := readMetafile(config, clientMetafileName)
+	if err != nil {
+		return nil, err
+	}
+	serverMeta, err := readMetafile(config, serverMetafileName)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := newAssetSizer(config)
+	report := &BundleReport{}
+
+	// 页面和共享 chunk
+	chunkPages := map[string][]string{}
+	for outPath, output := range clientMeta.Outputs {
+		if output.EntryPoint == "" || !strings.HasSuffix(outPath, ".js") {
+			continue
+		}
+
+		name, err := entryNameFromMetafile(config, output.EntryPoint)
+		if err != nil {
+			return nil, err
+		}
+
+		page := PageSize{Name: name, Entry: sizes.of(outPath)}
+		for _, chunk := range staticImportGraph(clientMeta, outPath) {
+			page.Chunks = append(page.Chunks, sizes.of(chunk))
+			chunkPages[chunk] = append(chunkPages[chunk], name)
+		}
+		if output.CSSBundle != "" {
+			page.CSS = append(page.CSS, sizes.of(output.CSSBundle))
+		}
+
+		for _, asset := range append(append([]AssetSize{page.Entry}, page.Chunks...), page.CSS...) {
+			page.TotalBytes += asset.Bytes
+			page.TotalGzip += asset.Gzip
+		}
+		page.Budget = budgetFor(config.Budgets, strings.TrimPrefix(name, appEntryName+"/"))
+		report.Pages = append(report.Pages, page)
+	}
+	sort.Slice(report.Pages, func(i, j int) bool {
+		if report.Pages[i].TotalGzip != report.Pages[j].TotalGzip {
+			return report.Pages[i].TotalGzip > report.Pages[j].TotalGzip
+		}
+		return report.Pages[i].Name < report.Pages[j].Name
+	})
+
+	for chunk, pages := range chunkPages {
+		if len(pages) < 2 {
+			continue
+		}
+		sort.Strings(pages)
+		report.SharedChunks = append(report.SharedChunks, ChunkSize{AssetSize: sizes.of(chunk), Pages: pages})
+	}
+	sort.Slice(report.SharedChunks, func(i, j int) bool {
+		return report.SharedChunks[i].Bytes > report.SharedChunks[j].Bytes
+	})
+
+	// 按模块汇总在所有客户端产物中的大小
+	modules := map[string]int{}
+	for _, output := range clientMeta.Outputs {
+		for input, in := range output.Inputs {
+			modules[input] += in.BytesInOutput
+		}
+	}
+	for input, size := range modules {
+		report.TopModules = append(report.TopModules, ModuleSize{Path: input, Bytes: size})
+	}
+	sort.Slice(report.TopModules, func(i, j int) bool {
+		if report.TopModules[i].Bytes != report.TopModules[j].Bytes {
+			return report.TopModules[i].Bytes > report.TopModules[j].Bytes
+		}
+		return report.TopModules[i].Path < report.TopModules[j].Path
+	})
+	if len(report.TopModules) > reportTopModules {
+		report.TopModules = report.TopModules[:reportTopModules]
+	}
+
+	for outPath, output := range serverMeta.Outputs {
+		if output.EntryPoint != "" {
+			report.Server = append(report.Server, sizes.of(outPath))
+		}
+	}
+	sort.Slice(report.Server, func(i, j int) bool {
+		return report.Server[i].Bytes > report.Server[j].Bytes
+	})
+
+	return report, nil
+}
+
+// CheckBudgets 返回超出预算的页面, 没有超出时返回 nil
+func (r *BundleReport) CheckBudgets() error {
+	var errs []error
+	for _, page := range r.Pages {
+		if page.OverBudget() {
+			errs = append(errs, fmt.Errorf("%s is %s gzipped, over its budget of %s", page.Name, FormatSize(int64(page.TotalGzip)), FormatSize(page.Budget)))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("size budget exceeded:\n%w", errors.Join(errs...))
+}
+
+// WriteText 输出文本格式的报告
+func (r *BundleReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	section := func(title string) {
+		fmt.Fprintf(tw, "\n%s\n", title)
+	}
+
+	section("PAGES (entry + static chunks + css)")
+	fmt.Fprintln(tw, "PAGE\tENTRY\tCHUNKS\tCSS\tTOTAL\tGZIP\tBUDGET")
+	for _, page := range r.Pages {
+		budget := "-"
+		if page.Budget > 0 {
+			budget = FormatSize(page.Budget)
+			if page.OverBudget() {
+				budget += " ✘"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", page.Name, FormatSize(int64(page.Entry.Bytes)), len(page.Chunks), len(page.CSS),
+			FormatSize(int64(page.TotalBytes)), FormatSize(int64(page.TotalGzip)), budget)
+	}
+
+	section("SHARED CHUNKS")
+	fmt.Fprintln(tw, "CHUNK\tSIZE\tGZIP\tPAGES")
+	for _, chunk := range r.SharedChunks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", chunk.File, FormatSize(int64(chunk.Bytes)), FormatSize(int64(chunk.Gzip)), len(chunk.Pages))
+	}
+
+	section("TOP MODULES (bytes in client output)")
+	fmt.Fprintln(tw, "MODULE\tSIZE")
+	for _, module := range r.TopModules {
+		fmt.Fprintf(tw, "%s\t%s\n", module.Path, FormatSize(int64(module.Bytes)))
+	}
+
+	section("SERVER BUNDLES")
+	fmt.Fprintln(tw, "BUNDLE\tSIZE\tGZIP")
+	for _, bundle := range r.Server {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", bundle.File, FormatSize(int64(bundle.Bytes)), FormatSize(int64(bundle.Gzip)))
+	}
+
+	return tw.Flush()
+}
+
+// WriteHTML 输出 HTML 格式的报告
+func (r *BundleReport) WriteHTML(w io.Writer) error {
+	tmpl, err := template.New("analyze").Funcs(template.FuncMap{
+		"size": func(n any) string {
+			switch v := n.(type) {
+			case int:
+				return FormatSize(int64(v))
+			case int64:
+				return FormatSize(v)
+			}
+			return fmt.Sprint(n)
+		},
+		"percent": func(part, total int) string {
+			if total == 0 {
+				return "0"
+			}
+			return strconv.FormatFloat(float64(part)*100/float64(total), 'f', 1, 64)
+		},
+		"join": strings.Join,
+	}).Parse(analyzeTemplate)
+	if err != nil {
+		return err
+	}
+
+	maxModule := 0
+	if len(r.TopModules) > 0 {
+		maxModule = r.TopModules[0].Bytes
+	}
+	return tmpl.Execute(w, map[string]any{"Report": r, "MaxModule": maxModule})
+}
+
+// SaveReports 将文本和 HTML 报告写入 MetaDir, 返回 HTML 报告的路径
+func (r *BundleReport) SaveReports(config *BuildConfig) (string, error) {
+	if err := os.MkdirAll(config.MetaDir, 0755); err != nil {
+		return "", err
+	}
+
+	var text, html bytes.Buffer
+	if err := r.WriteText(&text); err != nil {
+		return "", err
+	}
+	if err := r.WriteHTML(&html); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(config.MetaDir, ReportTextFileName), text.Bytes(), DefaultFileMode); err != nil {
+		return "", err
+	}
+	htmlPath := filepath.Join(config.MetaDir, ReportHTMLFileName)
+	return htmlPath, os.WriteFile(htmlPath, html.Bytes(), DefaultFileMode)
+}
+
+// writeMetafiles 保存客户端和服务端的 metafile
+func writeMetafiles(config *BuildConfig, client, server string) error {
+	if err := os.MkdirAll(config.MetaDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(config.MetaDir, clientMetafileName), []byte(client), DefaultFileMode); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(config.MetaDir, serverMetafileName), []byte(server), DefaultFileMode)
+}
+
+// checkBudgets 构建完成后检查页面体积预算, 生产模式下超出时返回错误, 开发模式下只输出警告
+func checkBudgets(config *BuildConfig) error {
+	if len(config.Budgets) == 0 {
+		return nil
+	}
+
+	report, err := AnalyzeBuild(config)
+	if err != nil {
+		return err
+	}
+
+	err = report.CheckBudgets()
+	if err != nil && config.Mode != ModeProduction {
+		xlog.Warn("size budget exceeded", xlog.Err(err))
+		return nil
+	}
+	return err
+}
+
+func readMetafile(config *BuildConfig, name string) (*esbuildMetafile, error) {
+	content, err := os.ReadFile(filepath.Join(config.MetaDir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found, run a build first", filepath.Join(config.MetaDir, name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseMetafile(string(content))
+}
+
+// entryNameFromMetafile metafile 中的入口路径转换为清单中的入口名称, 例如 app/Home
+func entryNameFromMetafile(config *BuildConfig, entryPoint string) (string, error) {
+	rel, err := filepath.Rel(config.TmpFrontendDir, filepath.Join(config.RootDir, entryPoint))
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), nil
+}
+
+// budgetFor 页面适用的预算: 精确匹配优先, 其次是最长的匹配模式 (长度相同时按字典序), * 作为所有页面 (包括嵌套页面) 的默认预算
+func budgetFor(budgets map[string]int64, page string) int64 {
+	if budget, ok := budgets[page]; ok {
+		return budget
+	}
+
+	var best string
+	var budget int64
+	for pattern, size := range budgets {
+		ok, _ := path.Match(pattern, page)
+		if ok && (len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+			best, budget = pattern, size
+		}
+	}
+	if best == "" {
+		return budgets["*"]
+	}
+	return budget
+}
+
+// assetSizer 计算产物文件的原始大小和 gzip 大小, 同一文件只计算一次
+type assetSizer struct {
+	config *BuildConfig
+	cache  map[string]AssetSize
+}
+
+func newAssetSizer(config *BuildConfig) *assetSizer {
+	return &assetSizer{config: config, cache: map[string]AssetSize{}}
+}
+
+// of outPath 为 metafile 中相对于 RootDir 的路径
+func (s *assetSizer) of(outPath string) AssetSize {
+	if size, ok := s.cache[outPath]; ok {
+		return size
+	}
+
+	file := filepath.Join(s.config.RootDir, outPath)
+	size := AssetSize{File: filepath.ToSlash(outPath)}
+	if rel, err := filepath.Rel(s.config.BuildDir, file); err == nil {
+		size.File = filepath.ToSlash(rel)
+	}
+
+	if content, err := os.ReadFile(file); err == nil {
+		size.Bytes = len(content)
+		size.Gzip = gzipSize(content)
+	}
+
+	s.cache[outPath] = size
+	return size
+}
+
+func gzipSize(content []byte) int {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.Write(content)
+	w.Close()
+	return buf.Len()
+}
+
+// FormatSize 格式化字节数, 例如 1.5 KB
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 2, 64) + " MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + " KB"
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
+
+// ParseSize 解析大小, 支持 B、KB、MB (按 1024 计算), 例如 200KB、1.5MB、4096
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, suffix := range []struct {
+		name string
+		unit int64
+	}{{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"KB", 1 << 10}, {"MB", 1 << 20}, {"K", 1 << 10}, {"M", 1 << 20}, {"B", 1}} {
+		if strings.HasSuffix(value, suffix.name) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, suffix.name))
+			unit = suffix.unit
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
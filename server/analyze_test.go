@@ -0,0 +1,99 @@
+package server
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "4096", want: 4096},
+		{in: "200KB", want: 200 << 10},
+		{in: "200 kb", want: 200 << 10},
+		{in: "1.5MB", want: 3 << 19},
+		{in: "2MiB", want: 2 << 20},
+		{in: "64KiB", want: 64 << 10},
+		{in: "10k", want: 10 << 10},
+		{in: "1m", want: 1 << 20},
+		{in: "512B", want: 512},
+		{in: " 1 MB ", want: 1 << 20},
+		{in: "", err: true},
+		{in: "0", err: true},
+		{in: "-1KB", err: true},
+		{in: "KB", err: true},
+		{in: "ten", err: true},
+		{in: "1GB", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseSize(%q) err = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KB"},
+		{3 << 19, "1.50 MB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.in); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBudgetFor(t *testing.T) {
+	budgets := map[string]int64{
+		"*":          100,
+		"Home":       200,
+		"blog/*":     300,
+		"*/Index":    400,
+		"blog/Index": 500,
+		"docs/*/*":   600,
+	}
+
+	tests := []struct {
+		page string
+		want int64
+	}{
+		{"Home", 200},
+		{"About", 100},
+		{"blog/Index", 500},
+		{"blog/[slug]", 300},
+		{"shop/Index", 400},
+		{"docs/guide/Intro", 600},
+		{"admin/users/List", 100},
+	}
+
+	for _, tt := range tests {
+		if got := budgetFor(budgets, tt.page); got != tt.want {
+			t.Errorf("budgetFor(%q) = %d, want %d", tt.page, got, tt.want)
+		}
+	}
+
+	// 长度相同的模式按字典序选择, 结果不受 map 遍历顺序影响
+	tied := map[string]int64{"blog/I*": 1, "*/Intro": 2}
+	for i := 0; i < 20; i++ {
+		if got := budgetFor(tied, "blog/Intro"); got != 2 {
+			t.Fatalf("budgetFor tie = %d, want 2", got)
+		}
+	}
+
+	if got := budgetFor(nil, "Home"); got != 0 {
+		t.Errorf("budgetFor(nil) = %d, want 0", got)
+	}
+}
@@ -1,12 +1,18 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/daodao97/xgo/xlog"
 )
@@ -14,60 +20,142 @@ import (
 const (
 	// 文件权限常量
 	DefaultFileMode = 0644
+)
 
-	// 客户端入口模板
-	clientTemplateFormat = `import { %s } from "@/pages/%s";
-import { renderPage } from "@/core/lib/PageWrapper";
-
-renderPage({Component: %s});
-`
+// CacheManager 缓存管理结构体
+type CacheManager struct {
+	config *BuildConfig
+}
 
-	// 服务端入口模板
-	serverTemplateFormat = `import { %s } from "@/pages/%s";
-import { createServerRenderer } from "@/core/lib/ServerRender";
+// Clean 删除构建目录、遗留的临时构建目录、临时前端目录、metafile 目录以及当前项目在 os.TempDir() 中的 hash 缓存
+func Clean() error {
+	config := GetBuildConfig()
+	dirs := []string{config.BuildDir, config.TmpFrontendDir, config.MetaDir}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("删除 %s 失败: %w", dir, err)
+		}
+	}
+	if err := removeStaleStaging(config.BuildDir); err != nil {
+		return err
+	}
 
-globalThis.Render = createServerRenderer({ Component: %s });
-`
-)
+	for _, file := range config.cacheFiles() {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除 %s 失败: %w", file, err)
+		}
+	}
 
-// BuildConfig 构建配置结构体
-type BuildConfig struct {
-	FrontendDir    string
-	TmpFrontendDir string
-	ClientEntry    string
-	ServerEntry    string
-	PagesDir       string
-	BuildDir       string
-	BuildServerDir string
+	return nil
 }
 
-// CacheManager 缓存管理结构体
-type CacheManager struct {
-	config *BuildConfig
+// cacheFiles 构建配置在 os.TempDir() 中的 hash 缓存文件, 不影响其他项目的缓存
+// .env 文件随构建模式变化, 两种模式的缓存都包含在内
+func (c *BuildConfig) cacheFiles() []string {
+	files := []string{
+		getCacheFilePath(c.FrontendDir),
+		getFilesCacheFilePath(c.packageFiles()...),
+		getOptionsCacheFilePath(c.BuildDir),
+	}
+	for _, mode := range []BuildMode{ModeDevelopment, ModeProduction} {
+		config := *c
+		config.Mode = mode
+		files = append(files, getFilesCacheFilePath(config.envFiles()...))
+	}
+	return files
 }
 
-var globalConfig *BuildConfig
+// optionsHash 影响产物但不在前端目录和包文件中的构建配置的 hash, 变化时需要重新构建
+func (c *BuildConfig) optionsHash() (string, error) {
+	// CSS 构建流程以名称为键, 参数相同的不同流程的 hash 不同
+	var cssPipeline map[string]CSSPipeline
+	if c.CSS != nil {
+		cssPipeline = map[string]CSSPipeline{c.CSS.Name(): c.CSS}
+	}
+
+	// 注入前端代码的 NODE_ENV 和公开环境变量, 包括来自进程环境变量而不在 .env 文件中的
+	env, err := loadBuildEnv(c)
+	if err != nil {
+		return "", err
+	}
 
-func init() {
-	globalConfig = initBuildConfig()
+	// tsconfig.json/jsconfig.json 中的 paths 决定别名解析, 包括 extends 的配置文件
+	tsconfigHash, err := calculateFilesHash(c.tsconfigChain()...)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(struct {
+		Mode                BuildMode
+		Sourcemap           string
+		KeepConsole         bool
+		Targets             []string
+		ServerTargets       []string
+		Aliases             map[string]string
+		Tsconfig            string
+		ClientEntryTemplate string
+		ServerEntryTemplate string
+		EnvPrefix           string
+		Define              map[string]string
+		Env                 map[string]string
+		Budgets             map[string]int64
+		CSS                 map[string]CSSPipeline
+		FastRefresh         bool
+	}{
+		Mode:                c.Mode,
+		Sourcemap:           c.Sourcemap,
+		KeepConsole:         c.KeepConsole,
+		Targets:             c.Targets,
+		ServerTargets:       c.ServerTargets,
+		Aliases:             c.Aliases,
+		Tsconfig:            tsconfigHash,
+		ClientEntryTemplate: c.ClientEntryTemplate,
+		ServerEntryTemplate: c.ServerEntryTemplate,
+		EnvPrefix:           c.EnvPrefix,
+		Define:              c.Define,
+		Env:                 env.defines(false),
+		Budgets:             c.Budgets,
+		CSS:                 cssPipeline,
+		FastRefresh:         c.fastRefresh,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
 }
 
-// initBuildConfig 初始化构建配置
-func initBuildConfig() *BuildConfig {
-	pwd, _ := os.Getwd()
-	projectName := filepath.Base(pwd)
+// getOptionsCacheFilePath 构建配置的缓存文件路径, 以产物目录区分项目
+func getOptionsCacheFilePath(buildDir string) string {
+	dirHash := sha256.Sum256([]byte(buildDir))
+	fileName := fmt.Sprintf("goreact-options-cache-%x.txt", dirHash[:8])
+	return filepath.Join(os.TempDir(), fileName)
+}
 
-	tmpFrontendDir := filepath.Join(os.TempDir(), projectName+"-frontend")
+// isOptionsChanged 检查构建配置是否与上次成功构建时不同
+func isOptionsChanged(config *BuildConfig) (bool, func(), error) {
+	currentHash, err := config.optionsHash()
+	if err != nil {
+		return false, nil, fmt.Errorf("计算构建配置hash失败: %w", err)
+	}
 
-	return &BuildConfig{
-		FrontendDir:    filepath.Join(pwd, "frontend"),
-		TmpFrontendDir: tmpFrontendDir,
-		ClientEntry:    filepath.Join(tmpFrontendDir, "app"),
-		ServerEntry:    filepath.Join(tmpFrontendDir, "server"),
-		PagesDir:       filepath.Join(tmpFrontendDir, "pages"),
-		BuildDir:       filepath.Join(pwd, "build"),
-		BuildServerDir: filepath.Join(pwd, "build/server"),
+	cacheFile := getOptionsCacheFilePath(config.BuildDir)
+	clearCache := func() {
+		if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
+			xlog.Debug("清除构建配置缓存文件失败", xlog.String("file", cacheFile), xlog.String("error", err.Error()))
+		}
 	}
+
+	cachedHash, err := readCachedHash(cacheFile)
+	if err != nil {
+		// 第一次运行或缓存文件不存在，认为有变更
+		return true, clearCache, nil
+	}
+
+	if currentHash != cachedHash {
+		xlog.Debug("构建配置有变更", xlog.String("oldHash", cachedHash[:min(8, len(cachedHash))]), xlog.String("newHash", currentHash[:8]))
+		return true, clearCache, nil
+	}
+	return false, clearCache, nil
 }
 
 // BuildJS 构建 JavaScript 文件
@@ -77,7 +165,7 @@ func BuildJS() error {
 
 // BuildJSWithForce 构建 JavaScript 文件（可强制构建）
 func BuildJSWithForce(force bool) error {
-	builder := NewJSBuilder(globalConfig)
+	builder := NewJSBuilder(GetBuildConfig())
 	return builder.Build(force)
 }
 
@@ -85,6 +173,7 @@ func BuildJSWithForce(force bool) error {
 type JSBuilder struct {
 	config       *BuildConfig
 	cacheManager *CacheManager
+	typeCheck    *TypeCheckResult
 }
 
 // NewJSBuilder 创建新的 JavaScript 构建器
@@ -95,8 +184,19 @@ func NewJSBuilder(config *BuildConfig) *JSBuilder {
 	}
 }
 
+// buildMu 串行执行同一进程中的完整构建, 它们共享临时前端目录和 hash 缓存
+var buildMu sync.Mutex
+
 // Build 执行构建过程
 func (b *JSBuilder) Build(force bool) error {
+	buildMu.Lock()
+	defer buildMu.Unlock()
+
+	// 生产环境可能只部署构建产物, 所以前端目录只在构建时检查
+	if info, err := os.Stat(b.config.FrontendDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("frontend dir %s does not exist", b.config.FrontendDir)
+	}
+
 	// 检查缓存
 	shouldBuild, clearCaches, err := b.checkShouldBuild(force)
 	if err != nil {
@@ -105,7 +205,8 @@ func (b *JSBuilder) Build(force bool) error {
 
 	if !shouldBuild {
 		xlog.Debug("no changes detected, skipping build")
-		return nil
+		// 类型检查不依赖产物, 跳过打包时仍然执行, 保证 TypeCheck 和 TypeCheckFatal 与完整构建一致
+		return b.startTypeCheck()(false)
 	}
 
 	// 执行构建
@@ -127,8 +228,10 @@ func (b *JSBuilder) checkShouldBuild(force bool) (bool, []func(), error) {
 		xlog.Debug("force build requested")
 		// 获取清理函数但跳过检查
 		_, clearDirCache, _ := isDirChanged(b.config.FrontendDir)
-		_, clearFileCache, _ := isFileChanged("package.json", "package-lock.json")
-		clearCaches = append(clearCaches, clearDirCache, clearFileCache)
+		_, clearFileCache, _ := isFileChanged(b.config.packageFiles()...)
+		_, clearEnvCache, _ := isFileChanged(b.config.envFiles()...)
+		_, clearOptionsCache, _ := isOptionsChanged(b.config)
+		clearCaches = append(clearCaches, clearDirCache, clearFileCache, clearEnvCache, clearOptionsCache)
 		return true, clearCaches, nil
 	}
 
@@ -140,19 +243,64 @@ func (b *JSBuilder) checkShouldBuild(force bool) (bool, []func(), error) {
 	clearCaches = append(clearCaches, clearDirCache)
 
 	// 检查包文件变化
-	packageChanged, clearFileCache, err := isFileChanged("package.json", "package-lock.json")
+	packageChanged, clearFileCache, err := isFileChanged(b.config.packageFiles()...)
 	if err != nil {
 		return false, nil, err
 	}
 	clearCaches = append(clearCaches, clearFileCache)
 
-	return frontendChanged || packageChanged, clearCaches, nil
+	// 检查 .env 文件变化, 公开变量在构建时注入
+	envChanged, clearEnvCache, err := isFileChanged(b.config.envFiles()...)
+	if err != nil {
+		return false, nil, err
+	}
+	clearCaches = append(clearCaches, clearEnvCache)
+
+	// 检查构建配置变化, 例如构建模式、source map、目标环境和 Define
+	optionsChanged, clearOptionsCache, err := isOptionsChanged(b.config)
+	if err != nil {
+		return false, nil, err
+	}
+	clearCaches = append(clearCaches, clearOptionsCache)
+
+	return frontendChanged || packageChanged || envChanged || optionsChanged, clearCaches, nil
 }
 
 // executeBuild 执行构建步骤
 func (b *JSBuilder) executeBuild() error {
 	xlog.Debug("build js start")
 
+	// 产物先输出到临时目录, 全部成功后再整体替换构建目录, 构建过程中不会读到不完整的产物
+	staged, err := newStagingConfig(b.config)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staged.BuildDir)
+
+	// 类型检查与打包并行执行
+	waitTypeCheck := b.startTypeCheck()
+	stage := &JSBuilder{config: staged, cacheManager: b.cacheManager}
+	if err := stage.bundle(); err != nil {
+		waitTypeCheck(true)
+		return err
+	}
+	if err := waitTypeCheck(false); err != nil {
+		return err
+	}
+
+	if err := swapBuildDir(staged.BuildDir, b.config.BuildDir); err != nil {
+		return err
+	}
+	if err := rebaseMetafiles(b.config, staged.BuildDir); err != nil {
+		return err
+	}
+
+	reloadManifest()
+	return nil
+}
+
+// bundle 清理目录、安装依赖并构建 CSS 和 JS
+func (b *JSBuilder) bundle() error {
 	// 清理构建目录
 	if err := b.cleanBuildDirs(); err != nil {
 		return err
@@ -177,16 +325,14 @@ func (b *JSBuilder) executeBuild() error {
 	return b.buildJS()
 }
 
-// cleanBuildDirs 清理构建目录
+// cleanBuildDirs 清理临时前端目录, 构建目录在构建成功后整体替换
 func (b *JSBuilder) cleanBuildDirs() error {
-	os.RemoveAll(b.config.BuildDir)
-	os.RemoveAll(b.config.TmpFrontendDir)
-	return nil
+	return os.RemoveAll(b.config.TmpFrontendDir)
 }
 
 // installDependencies 安装依赖
 func (b *JSBuilder) installDependencies() error {
-	packageChanged, _, err := isFileChanged("package.json", "package-lock.json")
+	packageChanged, _, err := isFileChanged(b.config.packageFiles()...)
 	if err != nil {
 		return err
 	}
@@ -196,7 +342,7 @@ func (b *JSBuilder) installDependencies() error {
 	}
 
 	cmd := exec.Command("npm", "install")
-	cmd.Dir = "./"
+	cmd.Dir = b.config.RootDir
 	xlog.Debug("install dependencies", xlog.String("cmd", cmd.String()))
 
 	output, err := cmd.CombinedOutput()
@@ -217,9 +363,20 @@ func (b *JSBuilder) prepareFrontendFiles() error {
 
 // buildCSS 构建 CSS
 func (b *JSBuilder) buildCSS() error {
-	inputCSS := filepath.Join(b.config.FrontendDir, "css/tailwind-input.css")
-	outputCSS := filepath.Join(b.config.TmpFrontendDir, "css/tailwind.css")
-	return BuildCSS(inputCSS, outputCSS)
+	return buildCSS(b.config)
+}
+
+// buildCSS 使用配置的 CSS 构建流程构建全局样式
+func buildCSS(config *BuildConfig) error {
+	if config.CSS == nil {
+		return nil
+	}
+
+	xlog.Debug("build css", xlog.String("pipeline", config.CSS.Name()))
+	if err := config.CSS.Build(config); err != nil {
+		return fmt.Errorf("css pipeline %s: %w", config.CSS.Name(), err)
+	}
+	return nil
 }
 
 // buildJS 构建 JavaScript
@@ -232,27 +389,50 @@ func (b *JSBuilder) buildJS() error {
 
 	xlog.Debug("BuildJS: generate entry files")
 	// 生成入口文件
-	err = generateEntryFiles(b.config.PagesDir, b.config.ClientEntry, b.config.ServerEntry)
+	err = generateConfigEntryFiles(b.config)
+	if err != nil {
+		return err
+	}
+
+	aliases, err := b.config.pathAliases()
+	if err != nil {
+		return err
+	}
+
+	env, err := loadBuildEnv(b.config)
+	if err != nil {
+		return err
+	}
+
+	clientMeta, err := buildClientComponents(b.config.RootDir, b.config.ClientEntry, b.config.BuildDir, aliases, b.config.TmpFrontendDir, env, b.config.outputOptions())
+	if err != nil {
+		return err
+	}
+
+	_, serverMeta, err := buildServerComponents(b.config.RootDir, b.config.ServerEntry, b.config.BuildServerDir, aliases, env, b.config.outputOptions())
 	if err != nil {
 		return err
 	}
 
-	aliases := map[string]string{
-		"@": b.config.FrontendDir,
+	// 保存 metafile, 用于 goreact build --analyze 和体积预算
+	err = writeMetafiles(b.config, clientMeta, serverMeta)
+	if err != nil {
+		return err
 	}
 
-	err = BuildClientComponents(b.config.ClientEntry, b.config.BuildDir, aliases, b.config.TmpFrontendDir)
+	// copy public dir to build dir
+	err = copyDir(b.config.PublicDir, b.config.BuildDir)
 	if err != nil {
 		return err
 	}
 
-	_, err = BuildServerComponents(b.config.ServerEntry, b.config.BuildServerDir, aliases)
+	// 为客户端产物生成 .br/.gz, 服务端 bundle 不需要
+	err = precompressAssets(b.config.BuildDir, b.config.BuildServerDir)
 	if err != nil {
 		return err
 	}
 
-	// copy frontend/public to build/public
-	err = copyDir(filepath.Join(b.config.FrontendDir, "public"), b.config.BuildDir)
+	err = checkBudgets(b.config)
 	if err != nil {
 		return err
 	}
@@ -290,9 +470,23 @@ func (cm *CacheManager) UpdateAllCaches() error {
 		xlog.Debug("更新文件缓存失败", xlog.String("error", err.Error()))
 	}
 
+	// 更新构建配置缓存
+	if err := cm.updateOptionsCache(); err != nil {
+		xlog.Debug("更新构建配置缓存失败", xlog.String("error", err.Error()))
+	}
+
 	return nil
 }
 
+// updateOptionsCache 更新构建配置缓存
+func (cm *CacheManager) updateOptionsCache() error {
+	currentHash, err := cm.config.optionsHash()
+	if err != nil {
+		return err
+	}
+	return writeCachedHash(getOptionsCacheFilePath(cm.config.BuildDir), currentHash)
+}
+
 // updateDirCache 更新目录缓存
 func (cm *CacheManager) updateDirCache() error {
 	currentHash, err := calculateDirHash(cm.config.FrontendDir)
@@ -311,58 +505,86 @@ func (cm *CacheManager) updateDirCache() error {
 
 // updateFileCache 更新文件缓存
 func (cm *CacheManager) updateFileCache() error {
-	currentHash, err := calculateFilesHash("package.json", "package-lock.json")
+	currentHash, err := calculateFilesHash(cm.config.packageFiles()...)
 	if err != nil {
 		return err
 	}
 
-	cacheFile := getFilesCacheFilePath("package.json", "package-lock.json")
+	cacheFile := getFilesCacheFilePath(cm.config.packageFiles()...)
 	if err := writeCachedHash(cacheFile, currentHash); err != nil {
 		return err
 	}
 
+	envHash, err := calculateFilesHash(cm.config.envFiles()...)
+	if err != nil {
+		return err
+	}
+	if err := writeCachedHash(getFilesCacheFilePath(cm.config.envFiles()...), envHash); err != nil {
+		return err
+	}
+
 	xlog.Debug("更新文件缓存成功", xlog.String("hash", currentHash[:8]))
 	return nil
 }
 
+// BuildCSS 使用 Tailwind CLI 构建 CSS
 func BuildCSS(inputCSS, outputCSS string) error {
-	cmd := exec.Command("npx", "@tailwindcss/cli", "-i", inputCSS, "-o", outputCSS, "--postcss")
-	cmd.Dir = "./"
-	xlog.Debug("build css", xlog.String("cmd", cmd.String()))
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		xlog.Error("build css", xlog.String("err", err.Error()), xlog.String("output", string(output)))
-	} else {
-		xlog.Debug("build css", xlog.String("output", string(output)))
-	}
-	return err
+	return runCSSCommand("./", exec.Command("npx", "@tailwindcss/cli", "-i", inputCSS, "-o", outputCSS, "--postcss"))
 }
 
 // EntryFileGenerator 入口文件生成器
 type EntryFileGenerator struct {
-	pagesDir    string
-	clientEntry string
-	serverEntry string
+	pagesDir       string
+	clientEntry    string
+	serverEntry    string
+	clientTemplate string
+	serverTemplate string
 }
 
-// NewEntryFileGenerator 创建入口文件生成器
+// NewEntryFileGenerator 创建入口文件生成器, 使用默认的入口模板
 func NewEntryFileGenerator(pagesDir, clientEntry, serverEntry string) *EntryFileGenerator {
 	return &EntryFileGenerator{
-		pagesDir:    pagesDir,
-		clientEntry: clientEntry,
-		serverEntry: serverEntry,
+		pagesDir:       pagesDir,
+		clientEntry:    clientEntry,
+		serverEntry:    serverEntry,
+		clientTemplate: DefaultClientEntryTemplate,
+		serverTemplate: DefaultServerEntryTemplate,
 	}
 }
 
+// WithTemplates 设置入口模板, 为空的保持默认
+func (g *EntryFileGenerator) WithTemplates(client, server string) *EntryFileGenerator {
+	g.clientTemplate = defaultString(client, g.clientTemplate)
+	g.serverTemplate = defaultString(server, g.serverTemplate)
+	return g
+}
+
+// entryTemplateData 入口模板数据
+type entryTemplateData struct {
+	// 相对于 pages 且不带扩展名的导入路径, 例如 blog/Index
+	Import string
+	// 组件名, 即文件名, 例如 Index
+	Name string
+}
+
 // Generate 生成入口文件
 func (g *EntryFileGenerator) Generate() error {
+	clientTemplate, err := parseEntryTemplate("client", g.clientTemplate)
+	if err != nil {
+		return err
+	}
+	serverTemplate, err := parseEntryTemplate("server", g.serverTemplate)
+	if err != nil {
+		return err
+	}
+
 	pageFiles, err := g.getComponentFiles()
 	if err != nil {
 		return err
 	}
 
 	for _, file := range pageFiles {
-		if err := g.generateEntryFile(file); err != nil {
+		if err := g.generateEntryFile(file, clientTemplate, serverTemplate); err != nil {
 			return err
 		}
 	}
@@ -371,37 +593,36 @@ func (g *EntryFileGenerator) Generate() error {
 }
 
 // generateEntryFile 为单个组件生成入口文件
-func (g *EntryFileGenerator) generateEntryFile(file string) error {
-	baseName := filepath.Base(file)
-	componentName := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+// file 为相对于页面目录的路径, 入口文件保持相同的目录结构, 避免不同目录下的同名页面互相覆盖
+func (g *EntryFileGenerator) generateEntryFile(file string, clientTemplate, serverTemplate *template.Template) error {
+	relPath := filepath.ToSlash(file)
+	importPath := strings.TrimSuffix(relPath, path.Ext(relPath))
+	data := entryTemplateData{Import: importPath, Name: path.Base(importPath)}
 
 	// 生成客户端入口
-	if err := g.writeClientEntry(baseName, componentName); err != nil {
-		return err
+	if err := g.writeEntry(filepath.Join(g.clientEntry, file), clientTemplate, data); err != nil {
+		return fmt.Errorf("写入客户端入口失败: %w", err)
 	}
 
 	// 生成服务端入口
-	return g.writeServerEntry(baseName, componentName)
-}
-
-// writeClientEntry 写入客户端入口文件
-func (g *EntryFileGenerator) writeClientEntry(baseName, componentName string) error {
-	content := fmt.Sprintf(clientTemplateFormat, componentName, componentName, componentName)
-	clientPath := filepath.Join(g.clientEntry, baseName)
-
-	if err := os.WriteFile(clientPath, []byte(content), DefaultFileMode); err != nil {
-		return fmt.Errorf("写入客户端入口 %s 失败: %w", clientPath, err)
+	if err := g.writeEntry(filepath.Join(g.serverEntry, file), serverTemplate, data); err != nil {
+		return fmt.Errorf("写入服务端入口失败: %w", err)
 	}
 	return nil
 }
 
-// writeServerEntry 写入服务端入口文件
-func (g *EntryFileGenerator) writeServerEntry(baseName, componentName string) error {
-	content := fmt.Sprintf(serverTemplateFormat, componentName, componentName, componentName)
-	serverPath := filepath.Join(g.serverEntry, baseName)
+// writeEntry 写入入口文件, 按需创建子目录
+func (g *EntryFileGenerator) writeEntry(entryPath string, tmpl *template.Template, data entryTemplateData) error {
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return err
+	}
 
-	if err := os.WriteFile(serverPath, []byte(content), DefaultFileMode); err != nil {
-		return fmt.Errorf("写入服务端入口 %s 失败: %w", serverPath, err)
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return fmt.Errorf("%s: %w", entryPath, err)
+	}
+	if err := os.WriteFile(entryPath, content.Bytes(), DefaultFileMode); err != nil {
+		return fmt.Errorf("%s: %w", entryPath, err)
 	}
 	return nil
 }
@@ -417,6 +638,13 @@ func generateEntryFiles(pagesDir string, clientEntry string, serverEntry string)
 	return generator.Generate()
 }
 
+// generateConfigEntryFiles 使用构建配置中的目录和入口模板生成入口文件
+func generateConfigEntryFiles(config *BuildConfig) error {
+	return NewEntryFileGenerator(config.PagesDir, config.ClientEntry, config.ServerEntry).
+		WithTemplates(config.ClientEntryTemplate, config.ServerEntryTemplate).
+		Generate()
+}
+
 // ComponentScanner 组件扫描器
 type ComponentScanner struct {
 	rootDir string
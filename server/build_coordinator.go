@@ -0,0 +1,159 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xlog"
+)
+
+// BuildStatus 一次构建的结果
+type BuildStatus struct {
+	// 完整构建或增量构建
+	Full    bool   `json:"full"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// 增量构建中相对于 frontend 的变动文件
+	Changed   []string      `json:"changed,omitempty"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	// 合并到这次构建中的请求数
+	Requests int `json:"requests"`
+	// 只在 Status 中使用, 是否有构建正在进行
+	Building bool `json:"building"`
+
+	// 构建失败的原始错误, 用于在浏览器中显示错误位置
+	err error
+}
+
+// buildRequest 等待执行的构建, 构建进行中收到的请求合并到同一个 buildRequest
+type buildRequest struct {
+	full     bool
+	force    bool
+	requests int
+	done     chan struct{}
+	status   BuildStatus
+}
+
+// BuildCoordinator 串行执行 dev 模式下的构建请求
+// 构建进行中收到的请求合并为下一次构建, 完整构建包含增量构建, 请求方等待覆盖该请求的构建完成
+type BuildCoordinator struct {
+	full        func(force bool) error
+	incremental func() (*BuildResult, error)
+
+	// 构建期间持有写锁, View 持有读锁, 读取构建目录时不会与构建交错
+	outputs sync.RWMutex
+
+	mu      sync.Mutex
+	running bool
+	pending *buildRequest
+	last    *BuildStatus
+}
+
+// NewBuildCoordinator 创建构建协调器, full 执行完整构建, incremental 执行增量构建
+func NewBuildCoordinator(full func(force bool) error, incremental func() (*BuildResult, error)) *BuildCoordinator {
+	return &BuildCoordinator{full: full, incremental: incremental}
+}
+
+// Build 请求一次完整构建并等待完成
+func (c *BuildCoordinator) Build(force bool) BuildStatus {
+	return c.request(true, force)
+}
+
+// Rebuild 请求一次增量构建并等待完成
+func (c *BuildCoordinator) Rebuild() BuildStatus {
+	return c.request(false, false)
+}
+
+// View 在没有构建进行时执行 fn, fn 执行期间不会开始新的构建, 多个 View 可以同时执行
+func (c *BuildCoordinator) View(fn func() error) error {
+	c.outputs.RLock()
+	defer c.outputs.RUnlock()
+	return fn()
+}
+
+// Status 最近一次构建的结果, 还没有构建完成时 StartedAt 为零值
+func (c *BuildCoordinator) Status() BuildStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var status BuildStatus
+	if c.last != nil {
+		status = *c.last
+	}
+	status.Building = c.running
+	return status
+}
+
+func (c *BuildCoordinator) request(full, force bool) BuildStatus {
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = &buildRequest{done: make(chan struct{})}
+	}
+	req := c.pending
+	req.full = req.full || full
+	req.force = req.force || force
+	req.requests++
+
+	if !c.running {
+		c.running = true
+		go c.run()
+	}
+	c.mu.Unlock()
+
+	<-req.done
+	return req.status
+}
+
+// run 依次执行等待中的构建, 没有等待的请求时退出
+func (c *BuildCoordinator) run() {
+	for {
+		c.mu.Lock()
+		req := c.pending
+		c.pending = nil
+		if req == nil {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		c.outputs.Lock()
+		req.status = c.execute(req)
+		c.outputs.Unlock()
+
+		c.mu.Lock()
+		c.last = &req.status
+		c.mu.Unlock()
+		close(req.done)
+	}
+}
+
+func (c *BuildCoordinator) execute(req *buildRequest) BuildStatus {
+	status := BuildStatus{Full: req.full, StartedAt: time.Now(), Requests: req.requests}
+
+	var err error
+	if req.full {
+		err = c.full(req.force)
+		if err != nil {
+			xlog.Error("build js", xlog.Err(err))
+		}
+	} else {
+		var result *BuildResult
+		result, err = c.incremental()
+		// 构建在开始之前失败时可能没有结果
+		if result == nil {
+			result = &BuildResult{}
+		}
+		logBuildResult(result, err)
+		status.Changed = result.Changed
+	}
+
+	status.Duration = time.Since(status.StartedAt)
+	status.Success = err == nil
+	if err != nil {
+		status.Error = err.Error()
+		status.err = err
+	}
+	return status
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBuilds 记录协调器调用的构建, 第一次构建阻塞到 release 关闭
+type fakeBuilds struct {
+	mu      sync.Mutex
+	calls   []string
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func newFakeBuilds() *fakeBuilds {
+	return &fakeBuilds{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (f *fakeBuilds) record(call string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	first := len(f.calls) == 1
+	f.mu.Unlock()
+
+	f.started <- struct{}{}
+	if first {
+		<-f.release
+	}
+	return f.err
+}
+
+func (f *fakeBuilds) full(force bool) error {
+	if force {
+		return f.record("full force")
+	}
+	return f.record("full")
+}
+
+func (f *fakeBuilds) incremental() (*BuildResult, error) {
+	return &BuildResult{Changed: []string{"pages/Home.tsx"}}, f.record("incremental")
+}
+
+func (f *fakeBuilds) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// waitPending 等待构建进行中收到的请求数达到 n
+func waitPending(t *testing.T, c *BuildCoordinator, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		requests := 0
+		if c.pending != nil {
+			requests = c.pending.requests
+		}
+		c.mu.Unlock()
+		if requests == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d pending requests", n)
+}
+
+func TestBuildCoordinatorCoalesces(t *testing.T) {
+	tests := []struct {
+		name      string
+		requests  []func(c *BuildCoordinator) BuildStatus
+		wantCall  string
+		wantFull  bool
+		wantCount int
+	}{
+		{
+			name: "incremental requests merge",
+			requests: []func(c *BuildCoordinator) BuildStatus{
+				(*BuildCoordinator).Rebuild,
+				(*BuildCoordinator).Rebuild,
+				(*BuildCoordinator).Rebuild,
+			},
+			wantCall:  "incremental",
+			wantCount: 3,
+		},
+		{
+			name: "full build covers incremental",
+			requests: []func(c *BuildCoordinator) BuildStatus{
+				(*BuildCoordinator).Rebuild,
+				func(c *BuildCoordinator) BuildStatus { return c.Build(false) },
+			},
+			wantCall:  "full",
+			wantFull:  true,
+			wantCount: 2,
+		},
+		{
+			name: "force is kept",
+			requests: []func(c *BuildCoordinator) BuildStatus{
+				func(c *BuildCoordinator) BuildStatus { return c.Build(true) },
+				func(c *BuildCoordinator) BuildStatus { return c.Build(false) },
+			},
+			wantCall:  "full force",
+			wantFull:  true,
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builds := newFakeBuilds()
+			c := NewBuildCoordinator(builds.full, builds.incremental)
+
+			// 第一次构建阻塞, 之后的请求在构建进行中到达
+			first := make(chan BuildStatus, 1)
+			go func() { first <- c.Rebuild() }()
+			<-builds.started
+
+			statuses := make([]BuildStatus, len(tt.requests))
+			var wg sync.WaitGroup
+			for i, request := range tt.requests {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					statuses[i] = request(c)
+				}()
+			}
+			waitPending(t, c, len(tt.requests))
+
+			if status := c.Status(); !status.Building {
+				t.Errorf("Status().Building = false while building")
+			}
+
+			close(builds.release)
+			wg.Wait()
+
+			if status := <-first; status.Requests != 1 || status.Full {
+				t.Errorf("first status = %+v, want a single incremental request", status)
+			}
+			want := []string{"incremental", tt.wantCall}
+			if calls := builds.Calls(); len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+				t.Fatalf("calls = %v, want %v", calls, want)
+			}
+			for i, status := range statuses {
+				if !status.Success || status.Full != tt.wantFull || status.Requests != tt.wantCount {
+					t.Errorf("status[%d] = %+v, want full=%v requests=%d", i, status, tt.wantFull, tt.wantCount)
+				}
+			}
+
+			last := c.Status()
+			if last.Building || last.Requests != tt.wantCount {
+				t.Errorf("Status() = %+v, want the coalesced build", last)
+			}
+		})
+	}
+}
+
+func TestBuildCoordinatorError(t *testing.T) {
+	builds := newFakeBuilds()
+	builds.err = errors.New("syntax error")
+	close(builds.release)
+	c := NewBuildCoordinator(builds.full, builds.incremental)
+
+	status := c.Rebuild()
+	if status.Success || status.Error != "syntax error" || !errors.Is(status.err, builds.err) {
+		t.Errorf("status = %+v, want failed build", status)
+	}
+	if len(status.Changed) != 1 {
+		t.Errorf("Changed = %v, want the incremental result", status.Changed)
+	}
+
+	builds.err = nil
+	if status := c.Build(false); !status.Success || !status.Full {
+		t.Errorf("status = %+v, want successful full build", status)
+	}
+}
+
+func TestBuildCoordinatorView(t *testing.T) {
+	builds := newFakeBuilds()
+	c := NewBuildCoordinator(builds.full, builds.incremental)
+
+	// 构建进行中 View 等待构建完成
+	built := make(chan BuildStatus, 1)
+	go func() { built <- c.Rebuild() }()
+	<-builds.started
+
+	viewed := make(chan error, 1)
+	go func() { viewed <- c.View(func() error { return nil }) }()
+	select {
+	case <-viewed:
+		t.Fatal("View returned before the build finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(builds.release)
+	<-built
+	if err := <-viewed; err != nil {
+		t.Error(err)
+	}
+
+	// View 执行期间新的构建等待 View 返回
+	inView := make(chan struct{})
+	leave := make(chan struct{})
+	go c.View(func() error {
+		close(inView)
+		<-leave
+		return nil
+	})
+	<-inView
+	go c.Rebuild()
+	select {
+	case <-builds.started:
+		t.Fatal("build started during View")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(leave)
+	select {
+	case <-builds.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("build did not start after View returned")
+	}
+}
+
+func TestBuildCoordinatorNilResult(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"failed", errors.New("create client build context")},
+		{"succeeded", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewBuildCoordinator(func(bool) error { return nil }, func() (*BuildResult, error) { return nil, tt.err })
+			status := c.Rebuild()
+			if status.Success != (tt.err == nil) || len(status.Changed) != 0 {
+				t.Errorf("status = %+v", status)
+			}
+		})
+	}
+}
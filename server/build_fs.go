@@ -0,0 +1,69 @@
+package server
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	buildFSMu sync.RWMutex
+	// 构建产物, 为空时读取磁盘上的 BuildDir
+	buildFS fs.FS
+)
+
+// SetBuildFS 使用 fs.FS 提供构建产物, 静态资源、SSR bundle 和产物清单都从中读取
+// 根目录对应 BuildDir, 服务端 bundle 位于 BuildServerDir 相对于 BuildDir 的子目录 (默认 server)
+// 用于单文件部署, 产物不会在运行时变化, 不要在 dev 模式中使用:
+//
+//	//go:embed all:build
+//	var buildOutput embed.FS
+//
+//	dist, _ := fs.Sub(buildOutput, "build")
+//	server.SetBuildFS(dist)
+func SetBuildFS(fsys fs.FS) {
+	buildFSMu.Lock()
+	buildFS = fsys
+	buildFSMu.Unlock()
+
+	reloadManifest()
+}
+
+// BuildFS 返回当前的构建产物
+func BuildFS() fs.FS {
+	return configBuildFS(GetBuildConfig())
+}
+
+// configBuildFS 返回构建配置对应的构建产物, 设置了 SetBuildFS 时优先使用
+func configBuildFS(config *BuildConfig) fs.FS {
+	buildFSMu.RLock()
+	defer buildFSMu.RUnlock()
+
+	if buildFS != nil {
+		return buildFS
+	}
+	return os.DirFS(config.BuildDir)
+}
+
+// serverBundlePath 服务端 bundle 在构建产物中的路径, 例如 Home.js -> server/Home.js
+func serverBundlePath(config *BuildConfig, name string) string {
+	dir, err := filepath.Rel(config.BuildDir, config.BuildServerDir)
+	if err != nil {
+		dir = "server"
+	}
+	return path.Join(filepath.ToSlash(dir), name)
+}
+
+// readServerBundle 读取服务端 bundle
+func readServerBundle(config *BuildConfig, name string) ([]byte, error) {
+	return fs.ReadFile(configBuildFS(config), serverBundlePath(config, name))
+}
+
+// lazyBuildFS 每次打开文件时读取当前的构建产物, 注册路由之后调用 SetBuildFS 同样生效
+type lazyBuildFS struct{}
+
+func (lazyBuildFS) Open(name string) (fs.File, error) {
+	return BuildFS().Open(name)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// ModeEnv 构建模式的环境变量, goreact dev/build 设置 --mode 时传给应用, dev 服务器按该模式构建
+const ModeEnv = "GOREACT_MODE"
+
+// source map 的输出方式
+// .map 中包含完整的源码, 非 dev 环境下 StaticAssets 不提供 .map 文件, 需要时从构建目录上传到错误监控平台
+const (
+	// 生成 .map 文件并在产物末尾添加 sourceMappingURL 注释
+	SourcemapLinked = "linked"
+	// 只生成 .map 文件, 产物中没有 sourceMappingURL 注释, 用于上传到错误监控平台
+	SourcemapExternal = "external"
+	// source map 内联到产物中
+	SourcemapInline = "inline"
+	// 不生成 source map
+	SourcemapNone = "none"
+)
+
+// outputOptions 由构建模式决定的产物选项
+//
+//	development: 不压缩, linked source map, 保留 console, 优先保证增量构建速度
+//	production:  压缩空白、标识符和语法, 不生成 source map, 删除 console 和 debugger, 开启 tree shaking
+type outputOptions struct {
+	mode        BuildMode
+	sourcemap   string
+	keepConsole bool
+	// 客户端和 SSR bundle 的目标环境
+	targets       buildTargets
+	serverTargets buildTargets
+	// dev 服务器开启 Fast Refresh 时不为 nil, 客户端构建登记模块以便替换
+	refresh *refreshModules
+}
+
+// outputOptions 构建配置对应的产物选项
+func (c *BuildConfig) outputOptions() outputOptions {
+	// 目标环境已经在 Validate 中校验
+	targets, _ := parseTargets(c.Targets)
+	serverTargets, _ := parseTargets(c.ServerTargets)
+	output := outputOptions{
+		mode:          c.Mode,
+		sourcemap:     c.Sourcemap,
+		keepConsole:   c.KeepConsole,
+		targets:       targets,
+		serverTargets: serverTargets,
+	}
+	if c.fastRefresh && !output.production() {
+		output.refresh = c.refreshModules()
+	}
+	return output
+}
+
+func (o outputOptions) production() bool {
+	return o.mode != ModeDevelopment
+}
+
+// clientSourcemap 客户端 source map, 未配置时开发模式为 linked, 生产模式为 none
+func (o outputOptions) clientSourcemap() esbuild.SourceMap {
+	sourcemap := o.sourcemap
+	if sourcemap == "" {
+		sourcemap = SourcemapLinked
+		if o.production() {
+			sourcemap = SourcemapNone
+		}
+	}
+
+	switch sourcemap {
+	case SourcemapLinked:
+		return esbuild.SourceMapLinked
+	case SourcemapExternal:
+		return esbuild.SourceMapExternal
+	case SourcemapInline:
+		return esbuild.SourceMapInline
+	}
+	return esbuild.SourceMapNone
+}
+
+// applyClient 设置客户端构建的目标环境、压缩、source map 和 tree shaking
+func (o outputOptions) applyClient(options *esbuild.BuildOptions) {
+	options.Sourcemap = o.clientSourcemap()
+	o.targets.apply(options)
+	if !o.production() {
+		return
+	}
+
+	options.MinifyWhitespace = true
+	options.MinifyIdentifiers = true
+	options.MinifySyntax = true
+	options.TreeShaking = esbuild.TreeShakingTrue
+	// 许可证注释保留在产物末尾, external 生成的 .LEGAL.txt 会被当作静态资源公开访问
+	options.LegalComments = esbuild.LegalCommentsEndOfFile
+	options.Drop = esbuild.DropDebugger
+	if !o.keepConsole {
+		options.Drop |= esbuild.DropConsole
+	}
+}
+
+// applyServer 设置服务端构建, 服务端 bundle 在 v8 中执行
+// 开发模式下输出 external source map, 用于将 SSR 错误的位置映射回源码
+// 生产模式下压缩以减少每次渲染的解析时间, 保留 console 以便输出 SSR 日志
+func (o outputOptions) applyServer(options *esbuild.BuildOptions) {
+	o.serverTargets.apply(options)
+	if !o.production() {
+		options.Sourcemap = esbuild.SourceMapExternal
+		return
+	}
+
+	options.Sourcemap = esbuild.SourceMapNone
+
+	options.MinifyWhitespace = true
+	options.MinifyIdentifiers = true
+	options.MinifySyntax = true
+	options.TreeShaking = esbuild.TreeShakingTrue
+	options.LegalComments = esbuild.LegalCommentsNone
+	options.Drop = esbuild.DropDebugger
+}
+
+// validSourcemap 校验 source map 配置
+func validSourcemap(sourcemap string) error {
+	switch sourcemap {
+	case "", SourcemapLinked, SourcemapExternal, SourcemapInline, SourcemapNone:
+		return nil
+	}
+	return fmt.Errorf("unknown sourcemap %q, expected linked, external, inline or none", sourcemap)
+}
+
+// devConfig dev 服务器使用的构建配置, 默认使用开发模式, 设置了 GOREACT_MODE 时使用该模式
+func devConfig(config *BuildConfig) *BuildConfig {
+	mode := ModeDevelopment
+	if value := os.Getenv(ModeEnv); value != "" {
+		if parsed, err := ParseBuildMode(value); err == nil {
+			mode = parsed
+		}
+	}
+	if config.Mode == mode {
+		return config
+	}
+
+	dev := *config
+	dev.Mode = mode
+	return &dev
+}
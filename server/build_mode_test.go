@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+func TestClientSourcemap(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      BuildMode
+		sourcemap string
+		want      esbuild.SourceMap
+	}{
+		{"development default", ModeDevelopment, "", esbuild.SourceMapLinked},
+		{"production default", ModeProduction, "", esbuild.SourceMapNone},
+		{"empty mode is production", "", "", esbuild.SourceMapNone},
+		{"production external", ModeProduction, SourcemapExternal, esbuild.SourceMapExternal},
+		{"production linked", ModeProduction, SourcemapLinked, esbuild.SourceMapLinked},
+		{"development inline", ModeDevelopment, SourcemapInline, esbuild.SourceMapInline},
+		{"development none", ModeDevelopment, SourcemapNone, esbuild.SourceMapNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := outputOptions{mode: tt.mode, sourcemap: tt.sourcemap}
+			if got := o.clientSourcemap(); got != tt.want {
+				t.Errorf("clientSourcemap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyClientLegalComments(t *testing.T) {
+	tests := []struct {
+		name string
+		mode BuildMode
+		want esbuild.LegalComments
+	}{
+		{"development", ModeDevelopment, esbuild.LegalCommentsDefault},
+		{"production", ModeProduction, esbuild.LegalCommentsEndOfFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options esbuild.BuildOptions
+			outputOptions{mode: tt.mode}.applyClient(&options)
+			if options.LegalComments != tt.want {
+				t.Errorf("LegalComments = %v, want %v", options.LegalComments, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stagingPattern 临时构建目录的名称, 与构建目录位于同一目录中, 保证可以原子地 rename
+// 同级目录也使 source map 中的相对路径在交换后保持有效
+func stagingPattern(buildDir string) string {
+	return "." + filepath.Base(buildDir) + ".staging-*"
+}
+
+// newStagingConfig 创建临时构建目录, 返回输出到该目录的构建配置
+// 服务端 bundle 目录在临时目录中的相对位置与构建目录中一致
+func newStagingConfig(config *BuildConfig) (*BuildConfig, error) {
+	parent := filepath.Dir(config.BuildDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(parent, stagingPattern(config.BuildDir))
+	if err != nil {
+		return nil, err
+	}
+
+	serverRel, err := filepath.Rel(config.BuildDir, config.BuildServerDir)
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+
+	staged := *config
+	staged.BuildDir = staging
+	staged.BuildServerDir = filepath.Join(staging, serverRel)
+	return &staged, nil
+}
+
+// swapBuildDir 用临时目录中的产物替换构建目录, 替换过程中构建目录始终完整可用, 旧的产物随后删除
+func swapBuildDir(staging, buildDir string) error {
+	if _, err := os.Stat(buildDir); os.IsNotExist(err) {
+		return os.Rename(staging, buildDir)
+	}
+
+	if err := exchangeDirs(staging, buildDir); err != nil {
+		return fmt.Errorf("swap %s and %s: %w", staging, buildDir, err)
+	}
+	// 交换后 staging 中是旧的产物
+	return os.RemoveAll(staging)
+}
+
+// renameDirs 通过中间名称交换两个目录, 中间名称与临时目录的格式一致, 异常退出时会被清理
+func renameDirs(a, b string) error {
+	tmp := filepath.Join(filepath.Dir(b), strings.TrimSuffix(stagingPattern(b), "*")+"old")
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(b, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(a, b); err != nil {
+		os.Rename(tmp, b)
+		return err
+	}
+	return os.Rename(tmp, a)
+}
+
+// rebaseMetafiles 将 metafile 中临时目录的产物路径替换为构建目录, 供 goreact build --analyze 使用
+func rebaseMetafiles(config *BuildConfig, staging string) error {
+	from, err := filepath.Rel(config.RootDir, staging)
+	if err != nil {
+		return err
+	}
+	to, err := filepath.Rel(config.RootDir, config.BuildDir)
+	if err != nil {
+		return err
+	}
+	// 路径以 JSON 字符串的形式出现在 outputs 的键、imports 和 cssBundle 中
+	quoted := func(p string) string {
+		return strings.TrimSuffix(jsonString(filepath.ToSlash(p)+"/"), `"`)
+	}
+
+	for _, name := range []string{clientMetafileName, serverMetafileName} {
+		file := filepath.Join(config.MetaDir, name)
+		content, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rebased := strings.ReplaceAll(string(content), quoted(from), quoted(to))
+		if err := os.WriteFile(file, []byte(rebased), DefaultFileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleStaging 删除异常退出时遗留的临时构建目录
+func removeStaleStaging(buildDir string) error {
+	dirs, err := filepath.Glob(filepath.Join(filepath.Dir(buildDir), stagingPattern(buildDir)))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
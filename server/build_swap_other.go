@@ -0,0 +1,8 @@
+//go:build !linux
+
+package server
+
+// exchangeDirs 交换两个目录, 两次 rename 之间构建目录短暂不存在
+func exchangeDirs(a, b string) error {
+	return renameDirs(a, b)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptionsHash(t *testing.T) {
+	base := func() *BuildConfig {
+		return &BuildConfig{
+			Mode:    ModeProduction,
+			Targets: []string{"es2020"},
+			Define:  map[string]string{"__APP_VERSION__": `"1.0.0"`},
+			CSS:     &TailwindPipeline{},
+		}
+	}
+	baseHash, err := base().optionsHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, c *BuildConfig)
+		changed bool
+	}{
+		{"unchanged", func(t *testing.T, c *BuildConfig) {}, false},
+		{"build dir", func(t *testing.T, c *BuildConfig) { c.BuildDir = "other" }, false},
+		{"type check", func(t *testing.T, c *BuildConfig) { c.TypeCheck = true }, false},
+		{"mode", func(t *testing.T, c *BuildConfig) { c.Mode = ModeDevelopment }, true},
+		{"sourcemap", func(t *testing.T, c *BuildConfig) { c.Sourcemap = SourcemapExternal }, true},
+		{"keep console", func(t *testing.T, c *BuildConfig) { c.KeepConsole = true }, true},
+		{"targets", func(t *testing.T, c *BuildConfig) { c.Targets = []string{"es2017"} }, true},
+		{"server targets", func(t *testing.T, c *BuildConfig) { c.ServerTargets = []string{"es2020"} }, true},
+		{"define", func(t *testing.T, c *BuildConfig) { c.Define["__APP_VERSION__"] = `"1.0.1"` }, true},
+		{"env prefix", func(t *testing.T, c *BuildConfig) { c.EnvPrefix = "APP_" }, true},
+		{"client entry template", func(t *testing.T, c *BuildConfig) { c.ClientEntryTemplate = "x" }, true},
+		{"server entry template", func(t *testing.T, c *BuildConfig) { c.ServerEntryTemplate = "x" }, true},
+		{"css pipeline", func(t *testing.T, c *BuildConfig) { c.CSS = &PostCSSPipeline{} }, true},
+		{"css pipeline options", func(t *testing.T, c *BuildConfig) { c.CSS = &TailwindPipeline{Input: "css/app.css"} }, true},
+		{"fast refresh", func(t *testing.T, c *BuildConfig) { c.fastRefresh = true }, true},
+		{"public env from process", func(t *testing.T, c *BuildConfig) { t.Setenv("GOREACT_PUBLIC_API_URL", "https://prod") }, true},
+		{"private env from process", func(t *testing.T, c *BuildConfig) { t.Setenv("GOREACT_TEST_SECRET", "secret") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base()
+			tt.modify(t, c)
+			got, err := c.optionsHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := got != baseHash; changed != tt.changed {
+				t.Errorf("hash changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestOptionsHashTsconfig(t *testing.T) {
+	root := t.TempDir()
+	config := &BuildConfig{RootDir: root, FrontendDir: filepath.Join(root, "frontend")}
+	hash := func() string {
+		t.Helper()
+		h, err := config.optionsHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	steps := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"create tsconfig", map[string]string{"tsconfig.json": `{"extends": "./base.json"}`}},
+		{"create extended config", map[string]string{"base.json": `{"compilerOptions": {"paths": {"@/*": ["./frontend/*"]}}}`}},
+		{"edit extended paths", map[string]string{"base.json": `{"compilerOptions": {"paths": {"@/*": ["./src/*"]}}}`}},
+	}
+
+	previous := hash()
+	for _, step := range steps {
+		writeTestFiles(t, root, step.files)
+		current := hash()
+		if current == previous {
+			t.Errorf("%s: options hash did not change", step.name)
+		}
+		previous = current
+	}
+}
+
+func TestIsOptionsChanged(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	config := &BuildConfig{BuildDir: filepath.Join(t.TempDir(), "build"), Mode: ModeProduction}
+	cm := &CacheManager{config: config}
+
+	check := func(want bool) {
+		t.Helper()
+		changed, _, err := isOptionsChanged(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != want {
+			t.Fatalf("isOptionsChanged() = %v, want %v", changed, want)
+		}
+	}
+
+	check(true)
+	if err := cm.updateOptionsCache(); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+
+	config.Sourcemap = SourcemapExternal
+	check(true)
+	if err := cm.updateOptionsCache(); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+
+	_, clearCache, err := isOptionsChanged(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearCache()
+	check(true)
+}
+
+func TestBuildRunsTypeCheckWhenSkipped(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"tsconfig.json":           "{}",
+		"frontend/pages/Home.tsx": "export default function Home() { return null }",
+	})
+	config, err := NewBuildConfig(root, WithTmpDir(filepath.Join(root, "tmp")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Mode = ModeProduction
+	config.TypeCheck = true
+	config.TypeCheckFatal = true
+	config.TypeCheckCommand = []string{"sh", "-c", "echo 'frontend/pages/Home.tsx(1,1): error TS2322: bad type'; exit 2", "tsc"}
+
+	// 缓存与当前源码和配置一致, 打包被跳过
+	if err := (&CacheManager{config: config}).UpdateAllCaches(); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := NewJSBuilder(config)
+	err = builder.Build(false)
+	var typeErr *TypeCheckError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Build() error = %v, want TypeCheckError", err)
+	}
+	if result := builder.TypeCheck(); result == nil || result.Errors() != 1 {
+		t.Fatalf("TypeCheck() = %+v, want 1 error", result)
+	}
+	if _, err := os.Stat(config.BuildDir); !os.IsNotExist(err) {
+		t.Errorf("build dir exists, bundling should have been skipped")
+	}
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/daodao97/xgo/xapp"
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 响应压缩的默认最小字节数, 小于该值的响应不压缩
+	DefaultCompressMinSize = 1024
+
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// 构建时预压缩的静态资源扩展名
+var precompressExtensions = []string{".js", ".css", ".svg"}
+
+// 可以压缩的响应类型
+var compressibleTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/xml",
+	"application/javascript",
+	"text/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// precompressAssets 为构建目录中的 JS/CSS/SVG 生成 .br 和 .gz 文件, skipDirs 中的目录不处理
+func precompressAssets(dir string, skipDirs ...string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && slices.Contains(skipDirs, p) {
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() || !slices.Contains(precompressExtensions, filepath.Ext(p)) {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if len(content) < DefaultCompressMinSize {
+			return nil
+		}
+
+		if err := writeCompressed(p+".br", content, func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotli.BestCompression)
+		}); err != nil {
+			return err
+		}
+
+		return writeCompressed(p+".gz", content, func(w io.Writer) io.WriteCloser {
+			gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return gz
+		})
+	})
+}
+
+// removeCompressed 删除文件的 .br 和 .gz 预压缩版本, 原文件更新或删除后调用, 避免继续返回旧内容
+func removeCompressed(p string) error {
+	for _, ext := range []string{".br", ".gz"} {
+		if err := os.Remove(p + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCompressed(dest string, content []byte, newWriter func(io.Writer) io.WriteCloser) error {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// 压缩后没有变小的文件不保留
+	if buf.Len() >= len(content) {
+		return nil
+	}
+
+	return os.WriteFile(dest, buf.Bytes(), DefaultFileMode)
+}
+
+// acceptedEncodings 根据 Accept-Encoding 返回支持的压缩方式, br 优先
+func acceptedEncodings(r *http.Request) []string {
+	accept := r.Header.Get("Accept-Encoding")
+	if accept == "" {
+		return nil
+	}
+
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var encodings []string
+	for _, enc := range []string{encodingBrotli, encodingGzip} {
+		if accepted[enc] {
+			encodings = append(encodings, enc)
+		}
+	}
+	return encodings
+}
+
+// StaticAssets 提供构建目录中的静态资源, 根据 Accept-Encoding 优先返回预压缩文件
+// 需要注册在带 *filepath 参数的路由上, 例如 /assets/*filepath
+func StaticAssets(fsys fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+		if !fs.ValidPath(name) || name == "." {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// source map 包含完整的前端源码, 只在 dev 环境中提供
+		if path.Ext(name) == ".map" && !xapp.IsDev() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil || info.IsDir() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		servedName := name
+		if slices.Contains(precompressExtensions, path.Ext(name)) {
+			c.Header("Vary", "Accept-Encoding")
+
+			for _, enc := range acceptedEncodings(c.Request) {
+				ext := ".br"
+				if enc == encodingGzip {
+					ext = ".gz"
+				}
+				if compressedInfo, err := fs.Stat(fsys, name+ext); err == nil && !compressedInfo.IsDir() {
+					servedName = name + ext
+					c.Header("Content-Encoding", enc)
+					break
+				}
+			}
+		}
+
+		file, err := fsys.Open(servedName)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		// 内容类型以原始文件为准, 而不是 .br/.gz
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			c.Header("Content-Type", contentType)
+		}
+
+		servedInfo, err := file.Stat()
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		if seeker, ok := file.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, name, servedInfo.ModTime(), seeker)
+			return
+		}
+
+		c.DataFromReader(http.StatusOK, servedInfo.Size(), c.Writer.Header().Get("Content-Type"), file, nil)
+	}
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+var brotliWriterPool = sync.Pool{
+	New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	},
+}
+
+// CompressMiddleware 对 SSR 等动态响应进行 gzip/brotli 压缩
+// 小于 minSize 的响应、已经编码的响应和流式响应 (如 /hmr 的 SSE) 不压缩
+func CompressMiddleware(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encodings := acceptedEncodings(c.Request)
+		if len(encodings) == 0 || c.Request.Method == http.MethodHead || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encodings[0],
+			minSize:        minSize,
+		}
+		c.Writer = cw
+		// 请求结束后外层中间件直接使用原始 ResponseWriter
+		// handler panic 由内层的 RecoveryMiddleware 处理, 它先调用 discardBuffered 丢弃未发送的内容再渲染错误页面
+		defer func() {
+			c.Writer = cw.ResponseWriter
+		}()
+
+		c.Next()
+
+		if err := cw.finish(); err != nil {
+			xlog.Warn("compress response failed", xlog.String("path", c.Request.URL.Path), xlog.Err(err))
+		}
+	}
+}
+
+// compressWriter 先缓冲响应, 达到 minSize 后再决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+
+	buf     []byte
+	decided bool
+	writer  io.WriteCloser
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.writer != nil {
+			return w.writer.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap 返回底层的 http.ResponseWriter, 用于发送 103 Early Hints 等不经过压缩的输出
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	if unwrapper, ok := w.ResponseWriter.(interface{ Unwrap() http.ResponseWriter }); ok {
+		return unwrapper.Unwrap()
+	}
+	return w.ResponseWriter
+}
+
+// Written 缓冲中有内容时视为已经开始输出, 这些内容最终会发送给客户端
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// discardBuffered 丢弃还没有发送的缓冲内容, 已经决定是否压缩 (内容已经发出) 时不做任何事
+func (w *compressWriter) discardBuffered() {
+	if !w.decided {
+		w.buf = nil
+	}
+}
+
+// Flush 流式响应在第一次 Flush 时决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.minSize); err != nil {
+			xlog.Warn("compress response failed", xlog.Err(err))
+		}
+	}
+
+	if flusher, ok := w.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// shouldCompress 根据响应头判断是否需要压缩
+func (w *compressWriter) shouldCompress() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return slices.Contains(compressibleTypes, contentType)
+}
+
+// decide 确定是否压缩并写出已经缓冲的内容
+func (w *compressWriter) decide(largeEnough bool) error {
+	w.decided = true
+
+	if largeEnough && w.shouldCompress() {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+
+		// 压缩后的内容与原始内容不同, 强 ETag 需要区分编码
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) {
+			header.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+w.encoding+`"`)
+		}
+
+		if w.encoding == encodingBrotli {
+			bw := brotliWriterPool.Get().(*brotli.Writer)
+			bw.Reset(w.ResponseWriter)
+			w.writer = bw
+		} else {
+			gz := gzipWriterPool.Get().(*gzip.Writer)
+			gz.Reset(w.ResponseWriter)
+			w.writer = gz
+		}
+	}
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if w.writer != nil {
+		_, err := w.writer.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish 请求结束时写出剩余内容并归还压缩器
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if w.writer == nil {
+		return nil
+	}
+
+	err := w.writer.Close()
+	switch writer := w.writer.(type) {
+	case *brotli.Writer:
+		brotliWriterPool.Put(writer)
+	case *gzip.Writer:
+		gzipWriterPool.Put(writer)
+	}
+	w.writer = nil
+	return err
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/daodao97/xgo/xapp"
+	"github.com/gin-gonic/gin"
+)
+
+func TestStaticAssets(t *testing.T) {
+	if xapp.IsDev() {
+		t.Skip("source maps are served in dev")
+	}
+
+	gin.SetMode(gin.TestMode)
+	fsys := fstest.MapFS{
+		"app.js":     {Data: []byte("console.log(1)")},
+		"app.js.gz":  {Data: []byte("gzip")},
+		"app.js.map": {Data: []byte("{}")},
+		"logo.png":   {Data: []byte("png")},
+	}
+	r := gin.New()
+	r.GET("/assets/*filepath", StaticAssets(fsys))
+
+	tests := []struct {
+		name     string
+		path     string
+		encoding string
+		status   int
+		body     string
+	}{
+		{"plain", "/assets/app.js", "", http.StatusOK, "console.log(1)"},
+		{"precompressed", "/assets/app.js", "gzip", http.StatusOK, "gzip"},
+		{"not precompressed", "/assets/logo.png", "gzip", http.StatusOK, "png"},
+		{"source map", "/assets/app.js.map", "", http.StatusNotFound, ""},
+		{"missing", "/assets/missing.js", "", http.StatusNotFound, ""},
+		{"directory", "/assets/", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.encoding != "" {
+				req.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewarePanicAfterPartialWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	renderer := CreateTemplateRenderer().(*TemplateRenderer)
+
+	r := gin.New()
+	r.HTMLRender = renderer
+	r.Use(CompressMiddleware(DefaultCompressMinSize), RecoveryMiddleware(renderer))
+	r.GET("/page", func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.String(http.StatusOK, "<p>partial page</p>")
+		panic("render failed")
+	})
+
+	// 部分页面还在 CompressMiddleware 的缓冲中, 应该被错误页面替换
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	body := w.Body.String()
+	if w.Header().Get("Content-Encoding") == encodingGzip {
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(data)
+	}
+	if strings.Contains(body, "partial page") {
+		t.Errorf("body contains the partial page: %q", body)
+	}
+	if !strings.Contains(body, "<html") {
+		t.Errorf("body = %q, want the error page", body)
+	}
+}
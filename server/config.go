@@ -0,0 +1,661 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/daodao97/xgo/xlog"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileName 默认的构建配置文件
+	ConfigFileName = "goreact.yaml"
+	// ConfigEnv 构建配置文件路径的环境变量, goreact dev/start 启动应用时设置, Gin() 没有指定配置时从中加载
+	ConfigEnv = "GOREACT_CONFIG"
+)
+
+const (
+	// 默认客户端入口模板
+	// 页面组件优先使用与文件同名的导出, 其次是默认导出 (例如 [slug].tsx)
+	DefaultClientEntryTemplate = `import * as Page from "@/pages/{{ .Import }}";
+import { renderPage } from "@/core/lib/PageWrapper";
+
+renderPage({Component: Page[{{ quote .Name }}] ?? Page.default});
+`
+
+	// 默认服务端入口模板
+	DefaultServerEntryTemplate = `import * as Page from "@/pages/{{ .Import }}";
+import { createServerRenderer } from "@/core/lib/ServerRender";
+
+globalThis.Render = createServerRenderer({ Component: Page[{{ quote .Name }}] ?? Page.default });
+`
+)
+
+// BuildMode 构建模式
+type BuildMode string
+
+const (
+	ModeDevelopment BuildMode = "development"
+	ModeProduction  BuildMode = "production"
+)
+
+// ParseBuildMode 解析构建模式, 支持 dev/development/prod/production
+func ParseBuildMode(mode string) (BuildMode, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "dev", "development":
+		return ModeDevelopment, nil
+	case "prod", "production":
+		return ModeProduction, nil
+	}
+	return "", fmt.Errorf("unknown build mode %q, expected development or production", mode)
+}
+
+// BuildConfig 构建配置, 所有目录都是绝对路径
+type BuildConfig struct {
+	// 项目根目录, package.json、node_modules 和 PostCSS 配置所在的目录
+	RootDir string
+	// 前端源码目录, 其中 pages 为页面目录
+	FrontendDir string
+	// 静态文件目录, 构建时复制到 BuildDir
+	PublicDir string
+	// 构建使用的临时目录, 以及其中的入口文件目录
+	TmpFrontendDir string
+	ClientEntry    string
+	ServerEntry    string
+	PagesDir       string
+	// 客户端产物目录和服务端 bundle 目录, BuildServerDir 必须位于 BuildDir 中
+	BuildDir       string
+	BuildServerDir string
+	// esbuild metafile 和分析报告的目录, 不作为静态资源对外提供, 默认 .goreact
+	MetaDir string
+	// import 别名, 例如 @ -> FrontendDir, 与 tsconfig.json/jsconfig.json 中 paths 的同名别名冲突时优先
+	Aliases map[string]string
+	// 入口文件模板 (text/template), 可用 .Import (相对于 pages 的导入路径)、.Name (组件名) 和 quote 函数
+	ClientEntryTemplate string
+	ServerEntryTemplate string
+	// 构建模式, 决定压缩、source map 和 NODE_ENV, 默认 production; dev 服务器默认使用 development
+	Mode BuildMode
+	// 客户端 source map: linked、external、inline、none, 为空时开发模式 linked, 生产模式 none
+	Sourcemap string
+	// 生产模式下保留 console 调用, 默认删除
+	KeepConsole bool
+	// 客户端 JS 和 CSS 的目标环境, 例如 es2020、safari14、safari >= 14, 默认 DefaultTargets
+	// esbuild CSS 流程直接按目标环境降级; Tailwind 和 PostCSS 流程通过 BROWSERSLIST 环境变量传给 autoprefixer 等插件,
+	// Tailwind v4 CLI 自身使用固定的浏览器范围, 不读取 BROWSERSLIST
+	Targets []string
+	// SSR bundle 的目标环境, 默认 esnext
+	ServerTargets []string
+	// 构建时执行 tsc --noEmit 检查类型, 与打包并行; dev 模式下在后台执行, 结果显示在浏览器中
+	TypeCheck bool
+	// 生产模式下有类型错误时构建失败
+	TypeCheckFatal bool
+	// 类型检查命令, 默认 DefaultTypeCheckCommand, 执行时追加 --project <tsconfig.json>
+	TypeCheckCommand []string
+	// 全局样式的构建流程, 默认 Tailwind CLI
+	CSS CSSPipeline
+	// 公开环境变量的前缀, 默认 GOREACT_PUBLIC_, 带前缀的变量通过 process.env.X 和 import.meta.env.X 注入前端代码
+	EnvPrefix string
+	// 额外注入前端代码的常量, 值为 JS 表达式, 例如 {"__APP_VERSION__": `"1.2.0"`}
+	Define map[string]string
+	// 页面体积预算, 键为页面名称或 path.Match 模式 (例如 Home、blog/*), * 为所有页面的默认预算, 值为 gzip 后的字节数
+	// 页面体积包括入口、静态导入的 chunk 和 CSS, 生产模式下超出预算时构建失败
+	Budgets map[string]int64
+
+	// dev 服务器在开发模式下找到 react-refresh 时开启, 客户端构建登记模块以便替换
+	fastRefresh bool
+}
+
+// BuildOption 构建配置选项
+type BuildOption func(*BuildConfig)
+
+// WithFrontendDir 设置前端源码目录, 同时作为 @ 别名和 public 目录的默认位置
+func WithFrontendDir(dir string) BuildOption {
+	return func(c *BuildConfig) {
+		c.FrontendDir = dir
+	}
+}
+
+// WithPublicDir 设置静态文件目录
+func WithPublicDir(dir string) BuildOption {
+	return func(c *BuildConfig) {
+		c.PublicDir = dir
+	}
+}
+
+// WithBuildDir 设置产物目录, serverDir 为空时使用 buildDir/server
+func WithBuildDir(buildDir, serverDir string) BuildOption {
+	return func(c *BuildConfig) {
+		c.BuildDir = buildDir
+		c.BuildServerDir = serverDir
+	}
+}
+
+// WithMetaDir 设置 metafile 和分析报告的目录
+func WithMetaDir(dir string) BuildOption {
+	return func(c *BuildConfig) {
+		c.MetaDir = dir
+	}
+}
+
+// WithTmpDir 设置构建使用的临时目录
+func WithTmpDir(dir string) BuildOption {
+	return func(c *BuildConfig) {
+		c.TmpFrontendDir = dir
+	}
+}
+
+// WithAliases 添加 import 别名, 相对路径相对于 RootDir
+func WithAliases(aliases map[string]string) BuildOption {
+	return func(c *BuildConfig) {
+		if c.Aliases == nil {
+			c.Aliases = map[string]string{}
+		}
+		for alias, dir := range aliases {
+			c.Aliases[alias] = dir
+		}
+	}
+}
+
+// WithEntryTemplates 设置入口文件模板, 为空的保持默认
+func WithEntryTemplates(client, server string) BuildOption {
+	return func(c *BuildConfig) {
+		c.ClientEntryTemplate = defaultString(client, c.ClientEntryTemplate)
+		c.ServerEntryTemplate = defaultString(server, c.ServerEntryTemplate)
+	}
+}
+
+// WithMode 设置构建模式
+func WithMode(mode BuildMode) BuildOption {
+	return func(c *BuildConfig) {
+		c.Mode = mode
+	}
+}
+
+// WithSourcemap 设置客户端 source map 的输出方式
+func WithSourcemap(sourcemap string) BuildOption {
+	return func(c *BuildConfig) {
+		c.Sourcemap = sourcemap
+	}
+}
+
+// WithKeepConsole 生产模式下保留 console 调用
+func WithKeepConsole(keep bool) BuildOption {
+	return func(c *BuildConfig) {
+		c.KeepConsole = keep
+	}
+}
+
+// WithTargets 设置客户端 JS 和 CSS 的目标环境
+func WithTargets(targets ...string) BuildOption {
+	return func(c *BuildConfig) {
+		c.Targets = targets
+	}
+}
+
+// WithServerTargets 设置 SSR bundle 的目标环境
+func WithServerTargets(targets ...string) BuildOption {
+	return func(c *BuildConfig) {
+		c.ServerTargets = targets
+	}
+}
+
+// WithTypeCheck 开启类型检查, fatal 为 true 时生产构建因类型错误失败
+func WithTypeCheck(fatal bool) BuildOption {
+	return func(c *BuildConfig) {
+		c.TypeCheck = true
+		c.TypeCheckFatal = fatal
+	}
+}
+
+// WithTypeCheckCommand 设置类型检查命令
+func WithTypeCheckCommand(command ...string) BuildOption {
+	return func(c *BuildConfig) {
+		c.TypeCheckCommand = command
+	}
+}
+
+// WithCSSPipeline 设置 CSS 构建流程
+func WithCSSPipeline(pipeline CSSPipeline) BuildOption {
+	return func(c *BuildConfig) {
+		c.CSS = pipeline
+	}
+}
+
+// WithEnvPrefix 设置公开环境变量的前缀
+func WithEnvPrefix(prefix string) BuildOption {
+	return func(c *BuildConfig) {
+		c.EnvPrefix = prefix
+	}
+}
+
+// WithDefine 添加注入前端代码的常量, 值为 JS 表达式
+func WithDefine(define map[string]string) BuildOption {
+	return func(c *BuildConfig) {
+		if c.Define == nil {
+			c.Define = map[string]string{}
+		}
+		for key, value := range define {
+			c.Define[key] = value
+		}
+	}
+}
+
+// WithBudgets 添加页面体积预算, 单位为 gzip 后的字节数
+func WithBudgets(budgets map[string]int64) BuildOption {
+	return func(c *BuildConfig) {
+		if c.Budgets == nil {
+			c.Budgets = map[string]int64{}
+		}
+		for page, size := range budgets {
+			c.Budgets[page] = size
+		}
+	}
+}
+
+// NewBuildConfig 以 rootDir 为项目根目录创建构建配置, 未设置的目录使用默认值:
+// frontend、frontend/public、build、build/server, 临时目录位于 os.TempDir()
+func NewBuildConfig(rootDir string, opts ...BuildOption) (*BuildConfig, error) {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &BuildConfig{
+		RootDir:             root,
+		ClientEntryTemplate: DefaultClientEntryTemplate,
+		ServerEntryTemplate: DefaultServerEntryTemplate,
+		Mode:                ModeProduction,
+		CSS:                 &TailwindPipeline{},
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	config.resolve()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// resolve 填充默认值并把相对路径转换为基于 RootDir 的绝对路径
+func (c *BuildConfig) resolve() {
+	abs := func(p, fallback string) string {
+		p = defaultString(p, fallback)
+		if p == "" || filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(c.RootDir, p)
+	}
+
+	c.FrontendDir = abs(c.FrontendDir, "frontend")
+	c.PublicDir = abs(c.PublicDir, filepath.Join(c.FrontendDir, "public"))
+	c.BuildDir = abs(c.BuildDir, "build")
+	c.BuildServerDir = abs(c.BuildServerDir, filepath.Join(c.BuildDir, "server"))
+	c.MetaDir = abs(c.MetaDir, ".goreact")
+
+	// 临时目录名包含项目路径的 hash, 避免同名项目互相覆盖
+	sum := sha256.Sum256([]byte(c.RootDir))
+	c.TmpFrontendDir = abs(c.TmpFrontendDir, filepath.Join(os.TempDir(), fmt.Sprintf("%s-%x-frontend", filepath.Base(c.RootDir), sum[:4])))
+	c.ClientEntry = filepath.Join(c.TmpFrontendDir, "app")
+	c.ServerEntry = filepath.Join(c.TmpFrontendDir, "server")
+	c.PagesDir = filepath.Join(c.TmpFrontendDir, "pages")
+
+	aliases := map[string]string{"@": c.FrontendDir}
+	for alias, dir := range c.Aliases {
+		aliases[alias] = abs(dir, "")
+	}
+	c.Aliases = aliases
+
+	c.EnvPrefix = defaultString(c.EnvPrefix, DefaultEnvPrefix)
+
+	if len(c.Targets) == 0 {
+		c.Targets = DefaultTargets
+	}
+	if len(c.ServerTargets) == 0 {
+		c.ServerTargets = DefaultServerTargets
+	}
+}
+
+// Validate 校验构建配置
+func (c *BuildConfig) Validate() error {
+	var errs []error
+
+	for _, dir := range []struct{ name, path string }{
+		{"root", c.RootDir},
+		{"frontend", c.FrontendDir},
+		{"public", c.PublicDir},
+		{"build", c.BuildDir},
+		{"server", c.BuildServerDir},
+		{"meta", c.MetaDir},
+		{"tmp", c.TmpFrontendDir},
+	} {
+		if !filepath.IsAbs(dir.path) {
+			errs = append(errs, fmt.Errorf("%s dir must be an absolute path, got %q", dir.name, dir.path))
+		}
+	}
+
+	if !isSubPath(c.BuildDir, c.BuildServerDir) || c.BuildDir == c.BuildServerDir {
+		errs = append(errs, fmt.Errorf("server dir %s must be inside build dir %s", c.BuildServerDir, c.BuildDir))
+	}
+
+	// 构建时会删除 BuildDir 和 TmpFrontendDir
+	for _, dir := range []string{c.BuildDir, c.TmpFrontendDir} {
+		if dir == c.RootDir || isSubPath(dir, c.RootDir) || isSubPath(dir, c.FrontendDir) || isSubPath(c.FrontendDir, dir) {
+			errs = append(errs, fmt.Errorf("%s is removed on every build and must not contain or be inside the project or frontend dir", dir))
+		}
+	}
+
+	for alias, dir := range c.Aliases {
+		if alias == "" || strings.ContainsAny(alias, "/*") || !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("invalid alias %q -> %q", alias, dir))
+		}
+	}
+
+	if _, err := parseEntryTemplate("client", c.ClientEntryTemplate); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseEntryTemplate("server", c.ServerEntryTemplate); err != nil {
+		errs = append(errs, err)
+	}
+
+	for page, size := range c.Budgets {
+		if _, err := path.Match(page, ""); err != nil || size <= 0 {
+			errs = append(errs, fmt.Errorf("invalid budget %q: %d", page, size))
+		}
+	}
+
+	if err := validSourcemap(c.Sourcemap); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := parseTargets(c.Targets); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseTargets(c.ServerTargets); err != nil {
+		errs = append(errs, fmt.Errorf("server targets: %w", err))
+	}
+
+	if !isEnvName(c.EnvPrefix) {
+		errs = append(errs, fmt.Errorf("invalid env prefix %q", c.EnvPrefix))
+	}
+
+	if c.Mode != ModeDevelopment && c.Mode != ModeProduction {
+		errs = append(errs, fmt.Errorf("unknown build mode %q", c.Mode))
+	}
+
+	return errors.Join(errs...)
+}
+
+// packageFiles 项目的依赖文件, 变动时需要重新安装依赖并完整构建
+func (c *BuildConfig) packageFiles() []string {
+	return []string{filepath.Join(c.RootDir, "package.json"), filepath.Join(c.RootDir, "package-lock.json")}
+}
+
+// isSubPath 判断 child 是否位于 parent 中
+func isSubPath(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseEntryTemplate 解析入口文件模板
+func parseEntryTemplate(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%s entry template is empty", name)
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"quote": quoteJS}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s entry template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// quoteJS 生成 JS 字符串字面量
+func quoteJS(s string) string {
+	return fmt.Sprintf("%q", s)
+}
+
+// buildConfigFile goreact.yaml 的结构, 相对路径相对于配置文件所在目录
+//
+//	frontend: frontend
+//	public: frontend/public
+//	build: build
+//	server: build/server
+//	meta: .goreact
+//	tmp: /tmp/myapp-frontend
+//	mode: production
+//	sourcemap: none
+//	keep_console: false
+//	targets: [es2020, safari >= 14, chrome >= 87]
+//	server_targets: [esnext]
+//	typecheck:
+//	  enabled: true
+//	  fatal: true
+//	  command: [npx, --no-install, tsc, --noEmit, --pretty, "false"]
+//	css: tailwind
+//	env_prefix: GOREACT_PUBLIC_
+//	define:
+//	  __APP_VERSION__: '"1.2.0"'
+//	budgets:
+//	  "*": 200KB
+//	  Home: 120KB
+//	aliases:
+//	  "~shared": ../shared
+//	entries:
+//	  client: |
+//	    import ...
+type buildConfigFile struct {
+	Root          string   `yaml:"root"`
+	Frontend      string   `yaml:"frontend"`
+	Public        string   `yaml:"public"`
+	Build         string   `yaml:"build"`
+	Server        string   `yaml:"server"`
+	Meta          string   `yaml:"meta"`
+	Tmp           string   `yaml:"tmp"`
+	Mode          string   `yaml:"mode"`
+	Sourcemap     string   `yaml:"sourcemap"`
+	KeepConsole   bool     `yaml:"keep_console"`
+	Targets       []string `yaml:"targets"`
+	ServerTargets []string `yaml:"server_targets"`
+	TypeCheck     struct {
+		Enabled bool     `yaml:"enabled"`
+		Fatal   bool     `yaml:"fatal"`
+		Command []string `yaml:"command"`
+	} `yaml:"typecheck"`
+	CSS       string            `yaml:"css"`
+	EnvPrefix string            `yaml:"env_prefix"`
+	Define    map[string]string `yaml:"define"`
+	Budgets   map[string]string `yaml:"budgets"`
+	Aliases   map[string]string `yaml:"aliases"`
+	Entries   struct {
+		Client string `yaml:"client"`
+		Server string `yaml:"server"`
+	} `yaml:"entries"`
+}
+
+// LoadBuildConfig 从 goreact.yaml 加载构建配置, opts 在配置文件之后生效
+func LoadBuildConfig(path string, opts ...BuildOption) (*BuildConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &buildConfigFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	rootDir := dir
+	if file.Root != "" {
+		rootDir = file.Root
+		if !filepath.IsAbs(rootDir) {
+			rootDir = filepath.Join(dir, rootDir)
+		}
+	}
+
+	// 配置文件中的相对路径相对于配置文件所在目录
+	rel := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	fileOpts := []BuildOption{
+		WithFrontendDir(rel(file.Frontend)),
+		WithPublicDir(rel(file.Public)),
+		WithBuildDir(rel(file.Build), rel(file.Server)),
+		WithMetaDir(rel(file.Meta)),
+		WithTmpDir(rel(file.Tmp)),
+		WithEntryTemplates(file.Entries.Client, file.Entries.Server),
+		WithSourcemap(file.Sourcemap),
+		WithTargets(file.Targets...),
+		WithServerTargets(file.ServerTargets...),
+		WithEnvPrefix(file.EnvPrefix),
+		WithDefine(file.Define),
+	}
+
+	if len(file.Aliases) > 0 {
+		aliases := make(map[string]string, len(file.Aliases))
+		for alias, p := range file.Aliases {
+			aliases[alias] = rel(p)
+		}
+		fileOpts = append(fileOpts, WithAliases(aliases))
+	}
+
+	if file.TypeCheck.Enabled {
+		fileOpts = append(fileOpts, WithTypeCheck(file.TypeCheck.Fatal))
+	}
+	if len(file.TypeCheck.Command) > 0 {
+		fileOpts = append(fileOpts, WithTypeCheckCommand(file.TypeCheck.Command...))
+	}
+
+	if file.KeepConsole {
+		fileOpts = append(fileOpts, WithKeepConsole(true))
+	}
+
+	if len(file.Budgets) > 0 {
+		budgets := make(map[string]int64, len(file.Budgets))
+		for page, size := range file.Budgets {
+			limit, err := ParseSize(size)
+			if err != nil {
+				return nil, fmt.Errorf("%s: budget %q: %w", path, page, err)
+			}
+			budgets[page] = limit
+		}
+		fileOpts = append(fileOpts, WithBudgets(budgets))
+	}
+
+	if file.Mode != "" {
+		mode, err := ParseBuildMode(file.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		fileOpts = append(fileOpts, WithMode(mode))
+	}
+
+	if file.CSS != "" {
+		pipeline, err := NewCSSPipeline(file.CSS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		fileOpts = append(fileOpts, WithCSSPipeline(pipeline))
+	}
+
+	config, err := NewBuildConfig(rootDir, append(fileOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// 当前使用的构建配置, 只整体替换不原地修改, 已经取得配置的构建和渲染不受之后的设置影响
+var (
+	globalConfigMu sync.RWMutex
+	globalConfig   *BuildConfig
+)
+
+func init() {
+	globalConfig = defaultBuildConfig()
+}
+
+// defaultBuildConfig 以当前目录为项目根目录的默认配置
+func defaultBuildConfig() *BuildConfig {
+	pwd, _ := os.Getwd()
+	config := &BuildConfig{
+		RootDir:             pwd,
+		ClientEntryTemplate: DefaultClientEntryTemplate,
+		ServerEntryTemplate: DefaultServerEntryTemplate,
+		Mode:                ModeProduction,
+		CSS:                 &TailwindPipeline{},
+	}
+	config.resolve()
+	return config
+}
+
+// SetBuildConfig 设置当前使用的构建配置, BuildJS、页面渲染、产物清单等都使用该配置
+func SetBuildConfig(config *BuildConfig) error {
+	if config == nil {
+		return errors.New("build config is nil")
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	globalConfigMu.Lock()
+	globalConfig = config
+	globalConfigMu.Unlock()
+
+	reloadManifest()
+	xlog.Debug("use build config", xlog.String("root", config.RootDir), xlog.String("build", config.BuildDir))
+	return nil
+}
+
+// GetBuildConfig 返回当前使用的构建配置
+func GetBuildConfig() *BuildConfig {
+	globalConfigMu.RLock()
+	defer globalConfigMu.RUnlock()
+	return globalConfig
+}
+
+// updateBuildConfig 复制当前使用的构建配置, 修改后替换
+func updateBuildConfig(fn func(config *BuildConfig)) {
+	globalConfigMu.Lock()
+	defer globalConfigMu.Unlock()
+
+	config := *globalConfig
+	fn(&config)
+	globalConfig = &config
+}
+
+// SetBuildMode 设置构建模式
+func SetBuildMode(mode BuildMode) {
+	updateBuildConfig(func(config *BuildConfig) {
+		config.Mode = mode
+	})
+}
+
+// SetTypeCheck 开启类型检查, fatal 为 true 时生产构建因类型错误失败
+func SetTypeCheck(fatal bool) {
+	updateBuildConfig(func(config *BuildConfig) {
+		config.TypeCheck = true
+		config.TypeCheckFatal = fatal
+	})
+}
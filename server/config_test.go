@@ -0,0 +1,45 @@
+package server
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestUpdateBuildConfig(t *testing.T) {
+	prev := GetBuildConfig()
+	t.Cleanup(func() {
+		globalConfigMu.Lock()
+		globalConfig = prev
+		globalConfigMu.Unlock()
+	})
+
+	before := GetBuildConfig()
+	mode := before.Mode
+	SetBuildMode(ModeDevelopment)
+	SetTypeCheck(true)
+	SetCSSPipeline(&EsbuildCSSPipeline{})
+
+	// 已经取得的配置不受影响
+	if before.Mode != mode || before.TypeCheck {
+		t.Errorf("previous config was modified: mode=%v typeCheck=%v", before.Mode, before.TypeCheck)
+	}
+	after := GetBuildConfig()
+	if after.Mode != ModeDevelopment || !after.TypeCheck || !after.TypeCheckFatal || after.CSS.Name() != (&EsbuildCSSPipeline{}).Name() {
+		t.Errorf("config = %+v, want updated settings", after)
+	}
+
+	// 与读取并发执行, 在 -race 下检查
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetBuildMode(ModeProduction)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = GetBuildConfig().outputOptions()
+		}()
+	}
+	wg.Wait()
+}
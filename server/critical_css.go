@@ -0,0 +1,430 @@
+package server
+
+import (
+	"html/template"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/daodao97/xgo/xlog"
+)
+
+var (
+	// 是否内联关键 CSS
+	criticalCSSEnabled = false
+	// 不出现在 SSR html 中但始终存在的 class, 例如模板中 <html class="dark">
+	criticalCSSSafelist = []string{"dark"}
+	// 页面关键 CSS 缓存, key 为 构建 hash + 组件名, 产物清单重新加载时清空
+	criticalCSSCache sync.Map
+)
+
+// 页面模板中始终存在的标签
+var criticalCSSBaseTags = []string{"html", "head", "body", "section", "script"}
+
+// 可以包含规则的分组 at-rule, 其中的规则需要逐条筛选
+var groupingAtRules = []string{"media", "supports", "layer", "container", "scope", "document"}
+
+// JS 中的字符串和模板字符串字面量, \x60 为反引号
+var jsStringPattern = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|\x60(?:[^\x60\\]|\\.)*\x60`)
+
+// SetCriticalCSS 开启或关闭关键 CSS 内联, safelist 为额外认为已使用的 class
+func SetCriticalCSS(enabled bool, safelist ...string) {
+	criticalCSSEnabled = enabled
+	if len(safelist) > 0 {
+		criticalCSSSafelist = safelist
+	}
+}
+
+// criticalCSS 返回页面的关键 CSS, 结果按构建 hash 和组件缓存
+// 用到的选择器从组件及其静态依赖的客户端代码中收集, 而不是某一次渲染的 html,
+// 不同数据渲染出的页面使用同一份关键 CSS
+func criticalCSS(component string) template.CSS {
+	if !criticalCSSEnabled {
+		return ""
+	}
+
+	manifest := currentManifest()
+	entry, ok := manifest.Entries[appEntryName]
+	if !ok || len(entry.CSS) == 0 {
+		return ""
+	}
+
+	cacheKey := manifest.Hash + ":" + component
+	if cached, ok := criticalCSSCache.Load(cacheKey); ok {
+		return cached.(template.CSS)
+	}
+
+	var stylesheet strings.Builder
+	for _, file := range entry.CSS {
+		content, err := fs.ReadFile(BuildFS(), file)
+		if err != nil {
+			xlog.Warn("read stylesheet for critical css failed", xlog.String("file", file), xlog.Err(err))
+			return ""
+		}
+		stylesheet.Write(content)
+	}
+
+	used, err := componentSelectors(manifest, component)
+	if err != nil {
+		xlog.Warn("collect selectors for critical css failed", xlog.String("component", component), xlog.Err(err))
+		return ""
+	}
+	css := template.CSS(extractCriticalCSS(stylesheet.String(), used))
+
+	criticalCSSCache.Store(cacheKey, css)
+	return css
+}
+
+// resetCriticalCSS 清空关键 CSS 缓存, 产物清单重新加载时调用
+func resetCriticalCSS() {
+	criticalCSSCache.Clear()
+}
+
+// usedSelectors 页面可能用到的标签、class 和 id
+type usedSelectors struct {
+	tags    map[string]bool
+	classes map[string]bool
+	ids     map[string]bool
+}
+
+func newUsedSelectors() *usedSelectors {
+	used := &usedSelectors{tags: map[string]bool{}, classes: map[string]bool{}, ids: map[string]bool{}}
+	for _, tag := range criticalCSSBaseTags {
+		used.tags[tag] = true
+	}
+	for _, class := range criticalCSSSafelist {
+		used.classes[class] = true
+	}
+	return used
+}
+
+// componentSelectors 收集公共入口和组件入口 (包括静态导入的 chunk) 中可能用到的选择器
+func componentSelectors(manifest *AssetManifest, component string) (*usedSelectors, error) {
+	used := newUsedSelectors()
+	for _, name := range []string{appEntryName, entryName(component)} {
+		entry, ok := manifest.Entries[name]
+		if !ok {
+			continue
+		}
+		for _, file := range append([]string{entry.File}, entry.Chunks...) {
+			content, err := fs.ReadFile(BuildFS(), file)
+			if err != nil {
+				return nil, err
+			}
+			used.addScript(string(content))
+		}
+	}
+	return used, nil
+}
+
+// addScript 将脚本中字符串字面量里的每个词都当作可能用到的标签、class 和 id
+// 与 Tailwind 扫描源码的方式一致, 动态拼接的 class 无法识别, 需要加入 safelist
+func (u *usedSelectors) addScript(script string) {
+	for _, literal := range jsStringPattern.FindAllString(script, -1) {
+		for _, token := range strings.Fields(literal[1 : len(literal)-1]) {
+			u.tags[strings.ToLower(token)] = true
+			u.classes[token] = true
+			u.ids[token] = true
+		}
+	}
+}
+
+// extractCriticalCSS 从完整样式表中筛选出页面用到的规则
+// @font-face、@keyframes、@property 等非分组 at-rule 和 @import、@layer 声明全部保留
+func extractCriticalCSS(css string, used *usedSelectors) string {
+	p := &cssParser{src: css}
+	var out strings.Builder
+	p.filterBlock(&out, used)
+	return out.String()
+}
+
+// cssParser 只处理筛选规则需要的结构: 规则、分组 at-rule 和其它 at-rule
+type cssParser struct {
+	src string
+	pos int
+}
+
+// filterBlock 处理一个规则列表, 直到遇到 } 或结束
+func (p *cssParser) filterBlock(out *strings.Builder, used *usedSelectors) {
+	for {
+		p.skipSpaceAndComments()
+		if p.pos >= len(p.src) || p.src[p.pos] == '}' {
+			return
+		}
+
+		prelude, terminator := p.readPrelude()
+		prelude = strings.TrimSpace(prelude)
+
+		if terminator == ';' {
+			// @import / @layer a, b; 等语句
+			if strings.HasPrefix(prelude, "@") {
+				out.WriteString(prelude + ";")
+			}
+			continue
+		}
+
+		if terminator != '{' {
+			return
+		}
+
+		if strings.HasPrefix(prelude, "@") {
+			name := strings.ToLower(strings.TrimLeft(strings.Fields(prelude + " ")[0], "@"))
+			if slices.Contains(groupingAtRules, name) {
+				var inner strings.Builder
+				p.filterBlock(&inner, used)
+				p.consume('}')
+				if inner.Len() > 0 {
+					out.WriteString(prelude + "{" + inner.String() + "}")
+				}
+				continue
+			}
+
+			out.WriteString(prelude + "{" + p.readBody() + "}")
+			continue
+		}
+
+		body := p.readBody()
+		if selectors := filterSelectors(prelude, used); selectors != "" {
+			out.WriteString(selectors + "{" + body + "}")
+		}
+	}
+}
+
+func (p *cssParser) skipSpaceAndComments() {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 4
+		case p.src[p.pos] == ' ' || p.src[p.pos] == '\n' || p.src[p.pos] == '\t' || p.src[p.pos] == '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// readPrelude 读取到 { 或 ; 为止, 返回内容和终止符
+func (p *cssParser) readPrelude() (string, byte) {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '"', '\'':
+			p.skipString(c)
+			continue
+		case '\\':
+			p.pos += 2
+			continue
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case '{', ';':
+			if depth == 0 {
+				prelude := p.src[start:p.pos]
+				p.pos++
+				return prelude, c
+			}
+		case '}':
+			if depth == 0 {
+				return p.src[start:p.pos], c
+			}
+		}
+		p.pos++
+	}
+	return p.src[start:], 0
+}
+
+// readBody 读取到与之匹配的 } 为止 (已经消费了 {), 返回 {} 中的内容
+func (p *cssParser) readBody() string {
+	start := p.pos
+	depth := 1
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '"', '\'':
+			p.skipString(c)
+			continue
+		case '\\':
+			p.pos += 2
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				body := p.src[start:p.pos]
+				p.pos++
+				return body
+			}
+		}
+		p.pos++
+	}
+	return p.src[start:]
+}
+
+func (p *cssParser) skipString(quote byte) {
+	p.pos++
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case quote:
+			p.pos++
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *cssParser) consume(c byte) {
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+}
+
+// filterSelectors 保留选择器列表中页面用到的部分
+func filterSelectors(selectorList string, used *usedSelectors) string {
+	var kept []string
+	for _, selector := range splitTopLevel(selectorList, ',') {
+		selector = strings.TrimSpace(selector)
+		if selector != "" && selectorMatches(selector, used) {
+			kept = append(kept, selector)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+var (
+	// :not(...) :where(...) 等带参数的伪类, 其中的条件不参与判断
+	functionalPseudoPattern = regexp.MustCompile(`::?[a-zA-Z-]+\(`)
+	selectorClassPattern    = regexp.MustCompile(`\.((?:\\[0-9a-fA-F]{1,6}\s?|\\.|[a-zA-Z0-9_-])+)`)
+	selectorIDPattern       = regexp.MustCompile(`#((?:\\[0-9a-fA-F]{1,6}\s?|\\.|[a-zA-Z0-9_-])+)`)
+	selectorTypePattern     = regexp.MustCompile(`(?:^|[\s>+~(])([a-zA-Z][a-zA-Z0-9-]*)`)
+	selectorAttrPattern     = regexp.MustCompile(`\[[^\]]*\]`)
+	selectorPseudoPattern   = regexp.MustCompile(`::?[a-zA-Z-]+`)
+)
+
+// selectorMatches 选择器中出现的 class、id 和标签都在页面中使用过时认为匹配
+// 伪类、属性选择器和组合关系不做判断, 宁可多保留
+func selectorMatches(selector string, used *usedSelectors) bool {
+	selector = stripFunctionalPseudo(selector)
+	selector = selectorAttrPattern.ReplaceAllString(selector, "")
+
+	for _, m := range selectorClassPattern.FindAllStringSubmatch(selector, -1) {
+		if !used.classes[cssUnescape(m[1])] {
+			return false
+		}
+	}
+
+	for _, m := range selectorIDPattern.FindAllStringSubmatch(selector, -1) {
+		if !used.ids[cssUnescape(m[1])] {
+			return false
+		}
+	}
+
+	// 去掉 class、id 和伪类后剩下的标识符才是标签
+	rest := selectorClassPattern.ReplaceAllString(selector, "")
+	rest = selectorIDPattern.ReplaceAllString(rest, "")
+	rest = selectorPseudoPattern.ReplaceAllString(rest, "")
+	for _, m := range selectorTypePattern.FindAllStringSubmatch(rest, -1) {
+		if !used.tags[strings.ToLower(m[1])] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stripFunctionalPseudo 去掉 :not(...) :where(...) 等伪类的参数部分
+func stripFunctionalPseudo(selector string) string {
+	for {
+		loc := functionalPseudoPattern.FindStringIndex(selector)
+		if loc == nil {
+			return selector
+		}
+
+		depth := 1
+		end := loc[1]
+		for end < len(selector) && depth > 0 {
+			switch selector[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			end++
+		}
+		selector = selector[:loc[0]] + selector[end:]
+	}
+}
+
+// splitTopLevel 按分隔符切分, 忽略括号中的分隔符
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cssUnescape 还原选择器中的转义, 例如 md\:flex -> md:flex, \31 0 -> 10
+func cssUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && j-i <= 6 && isHexDigit(s[j]) {
+			j++
+		}
+		if j > i+1 {
+			code, _ := strconv.ParseInt(s[i+1:j], 16, 32)
+			out.WriteRune(rune(code))
+			if j < len(s) && s[j] == ' ' {
+				j++
+			}
+			i = j - 1
+			continue
+		}
+
+		out.WriteByte(s[i+1])
+		i++
+	}
+	return out.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,53 @@
+package server
+
+import "testing"
+
+func TestExtractCriticalCSS(t *testing.T) {
+	used := newUsedSelectors()
+	used.addScript(`jsx("div",{className:"p-4 md:flex",children:[jsx("span",{id:'title'}),` + "`text-${c}`" + `]})`)
+
+	tests := []struct {
+		name string
+		css  string
+		want string
+	}{
+		{"class", ".p-4{padding:1rem}.m-4{margin:1rem}", ".p-4{padding:1rem}"},
+		{"escaped class", `.md\:flex{display:flex}`, `.md\:flex{display:flex}`},
+		{"tag", "div{color:red}table{color:blue}", "div{color:red}"},
+		{"base tag", "body{margin:0}", "body{margin:0}"},
+		{"id", "#title{color:red}#footer{color:blue}", "#title{color:red}"},
+		{"safelist", ".dark .p-4{color:white}", ".dark .p-4{color:white}"},
+		{"selector list", ".m-4,.p-4:hover{color:red}", ".p-4:hover{color:red}"},
+		{"media", "@media (min-width:768px){.p-4{padding:2rem}.m-4{margin:0}}", "@media (min-width:768px){.p-4{padding:2rem}}"},
+		{"empty media", "@media print{.m-4{margin:0}}", ""},
+		{"keyframes", "@keyframes spin{to{transform:rotate(1turn)}}", "@keyframes spin{to{transform:rotate(1turn)}}"},
+		{"import", `@import "a.css";.m-4{margin:0}`, `@import "a.css";`},
+		{"not", ".p-4:not(.m-4){color:red}", ".p-4:not(.m-4){color:red}"},
+		{"comment", "/* .p-4 */.m-4{margin:0}", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractCriticalCSS(tt.css, used); got != tt.want {
+				t.Errorf("extractCriticalCSS(%q) = %q, want %q", tt.css, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSSUnescape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"p-4", "p-4"},
+		{`md\:flex`, "md:flex"},
+		{`w-1\/2`, "w-1/2"},
+		{`\31 0`, "10"},
+	}
+
+	for _, tt := range tests {
+		if got := cssUnescape(tt.in); got != tt.want {
+			t.Errorf("cssUnescape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
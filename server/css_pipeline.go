@@ -0,0 +1,236 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/daodao97/xgo/xlog"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// CSSPipeline 全局样式的构建流程
+// 输入文件相对于 FrontendDir, 输出文件相对于 TmpFrontendDir, 由 app.js 引入后再交给 esbuild 打包
+// 组件中 import 的 CSS 和 .module.css (LoaderLocalCSS) 始终由 esbuild 在打包 JS 时处理
+type CSSPipeline interface {
+	// Name 流程名称, 用于日志和配置
+	Name() string
+	// Build 构建全局样式
+	Build(config *BuildConfig) error
+	// ScansContent 输出是否依赖组件中使用的 class, 例如 Tailwind
+	// 为 true 时 dev 模式下组件出现新的 class 也会重新构建 CSS
+	ScansContent() bool
+}
+
+// NewCSSPipeline 根据名称创建 CSS 构建流程: tailwind、postcss、esbuild
+func NewCSSPipeline(name string) (CSSPipeline, error) {
+	switch name {
+	case "", "tailwind":
+		return &TailwindPipeline{}, nil
+	case "postcss", "css":
+		return &PostCSSPipeline{}, nil
+	case "esbuild":
+		return &EsbuildCSSPipeline{}, nil
+	}
+	return nil, fmt.Errorf("unknown css pipeline %q, expected tailwind, postcss or esbuild", name)
+}
+
+// SetCSSPipeline 设置 CSS 构建流程
+func SetCSSPipeline(pipeline CSSPipeline) {
+	updateBuildConfig(func(config *BuildConfig) {
+		config.CSS = pipeline
+	})
+}
+
+// cssPaths 返回输入输出的绝对路径, 输入文件不存在时返回 false
+func cssPaths(config *BuildConfig, input, output string) (string, string, bool) {
+	inputCSS := filepath.Join(config.FrontendDir, input)
+	outputCSS := filepath.Join(config.TmpFrontendDir, output)
+
+	if _, err := os.Stat(inputCSS); err != nil {
+		xlog.Debug("css input not found, skip", xlog.String("input", inputCSS))
+		return inputCSS, outputCSS, false
+	}
+	return inputCSS, outputCSS, true
+}
+
+// TailwindPipeline 使用 Tailwind CLI 构建
+type TailwindPipeline struct {
+	// 默认 css/tailwind-input.css
+	Input string
+	// 默认 css/tailwind.css
+	Output string
+	// 默认 npx @tailwindcss/cli, 也可以使用独立的 tailwindcss 可执行文件
+	Command []string
+}
+
+func (p *TailwindPipeline) Name() string {
+	return "tailwind"
+}
+
+func (p *TailwindPipeline) ScansContent() bool {
+	return true
+}
+
+func (p *TailwindPipeline) Build(config *BuildConfig) error {
+	input, output, ok := cssPaths(config, defaultString(p.Input, "css/tailwind-input.css"), defaultString(p.Output, "css/tailwind.css"))
+	if !ok {
+		return nil
+	}
+
+	command := p.Command
+	if len(command) == 0 {
+		command = []string{"npx", "@tailwindcss/cli"}
+	}
+	args := append(command[1:len(command):len(command)], "-i", input, "-o", output, "--postcss")
+	cmd := exec.Command(command[0], args...)
+	cmd.Env = browserslistEnv(config)
+	return runCSSCommand(config.RootDir, cmd)
+}
+
+// PostCSSPipeline 普通 CSS, 项目中有 PostCSS 配置时使用 postcss-cli 处理, 否则原样复制
+type PostCSSPipeline struct {
+	// 默认 css/app.css
+	Input string
+	// 默认与 Input 相同, 覆盖复制到临时目录的源文件
+	Output string
+}
+
+// PostCSS 的配置文件
+var postcssConfigFiles = []string{
+	"postcss.config.js", "postcss.config.cjs", "postcss.config.mjs", "postcss.config.ts",
+	".postcssrc", ".postcssrc.json", ".postcssrc.yml", ".postcssrc.js", ".postcssrc.cjs",
+}
+
+func (p *PostCSSPipeline) Name() string {
+	return "postcss"
+}
+
+func (p *PostCSSPipeline) ScansContent() bool {
+	return false
+}
+
+func (p *PostCSSPipeline) Build(config *BuildConfig) error {
+	inputName := defaultString(p.Input, "css/app.css")
+	input, output, ok := cssPaths(config, inputName, defaultString(p.Output, inputName))
+	if !ok {
+		return nil
+	}
+
+	for _, name := range postcssConfigFiles {
+		if _, err := os.Stat(filepath.Join(config.RootDir, name)); err == nil {
+			cmd := exec.Command("npx", "postcss", input, "-o", output)
+			cmd.Env = browserslistEnv(config)
+			return runCSSCommand(config.RootDir, cmd)
+		}
+	}
+
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(output, content, DefaultFileMode)
+}
+
+// EsbuildCSSPipeline 使用 esbuild 打包全局样式, 处理 @import、嵌套语法和浏览器兼容, 按 BuildConfig.Targets 降级
+// 没有全局样式文件时不做任何事, 只使用组件中 import 的 CSS
+type EsbuildCSSPipeline struct {
+	// 默认 css/app.css
+	Input string
+	// 默认与 Input 相同
+	Output string
+}
+
+func (p *EsbuildCSSPipeline) Name() string {
+	return "esbuild"
+}
+
+func (p *EsbuildCSSPipeline) ScansContent() bool {
+	return false
+}
+
+func (p *EsbuildCSSPipeline) Build(config *BuildConfig) error {
+	inputName := defaultString(p.Input, "css/app.css")
+	input, output, ok := cssPaths(config, inputName, defaultString(p.Output, inputName))
+	if !ok {
+		return nil
+	}
+
+	options := esbuild.BuildOptions{
+		EntryPoints: []string{input},
+		Bundle:      true,
+		Write:       true,
+		Outfile:     output,
+		Loader: map[string]esbuild.Loader{
+			".css":        esbuild.LoaderCSS,
+			".module.css": esbuild.LoaderLocalCSS,
+		},
+		// url() 中的图片和字体保持原样
+		External:      []string{"*.png", "*.jpg", "*.jpeg", "*.gif", "*.svg", "*.webp", "*.woff", "*.woff2", "*.ttf", "*.eot"},
+		NodePaths:     []string{filepath.Join(config.RootDir, "node_modules")},
+		AbsWorkingDir: config.RootDir,
+	}
+	// 与客户端 JS 使用相同的目标环境
+	targets := config.outputOptions().targets
+	targets.apply(&options)
+
+	result := esbuild.Build(options)
+	targets.logWarnings("css", result.Warnings)
+	if len(result.Errors) > 0 {
+		return esbuildError(targets.annotate(result.Errors))
+	}
+	return nil
+}
+
+// CSSCommandError CSS 构建命令执行失败, Output 为命令的输出, 通常包含出错的文件和行号
+type CSSCommandError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *CSSCommandError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Command, e.Err)
+}
+
+func (e *CSSCommandError) Unwrap() error {
+	return e.Err
+}
+
+// browserslistEnv CSS 构建命令的环境变量, 通过 BROWSERSLIST 将 BuildConfig.Targets 传给 autoprefixer 等 PostCSS 插件
+// 已经设置 BROWSERSLIST 或目标环境中没有浏览器版本时返回 nil, 使用当前环境和项目自己的 browserslist 配置
+func browserslistEnv(config *BuildConfig) []string {
+	if os.Getenv("BROWSERSLIST") != "" {
+		return nil
+	}
+	query := config.outputOptions().targets.browserslist()
+	if query == "" {
+		return nil
+	}
+	return append(os.Environ(), "BROWSERSLIST="+query)
+}
+
+// runCSSCommand 在项目根目录中执行 CSS 构建命令
+func runCSSCommand(dir string, cmd *exec.Cmd) error {
+	cmd.Dir = dir
+	xlog.Debug("build css", xlog.String("cmd", cmd.String()))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		xlog.Error("build css", xlog.String("err", err.Error()), xlog.String("output", string(output)))
+		return &CSSCommandError{Command: cmd.String(), Output: strings.TrimSpace(string(output)), Err: err}
+	}
+	xlog.Debug("build css", xlog.String("output", string(output)))
+	return nil
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBrowserslistEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		targets []string
+		want    string
+	}{
+		{"browser targets", "", []string{"es2020", "chrome87", "safari >= 14"}, "BROWSERSLIST=chrome >= 87, safari >= 14"},
+		{"only es version", "", []string{"es2020"}, ""},
+		{"already set", "defaults", []string{"chrome87"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BROWSERSLIST", tt.env)
+
+			env := browserslistEnv(&BuildConfig{Targets: tt.targets})
+			if tt.want == "" {
+				if env != nil {
+					t.Fatalf("browserslistEnv() = %v, want nil", env)
+				}
+				return
+			}
+			if !slices.Contains(env, tt.want) {
+				t.Errorf("browserslistEnv() does not contain %q", tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,576 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xlog"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// 影响样式的文件, 变动后总是重新构建 CSS
+var styleExtensions = []string{".css", ".scss", ".sass", ".pcss"}
+
+// 可能包含 class 的文件, CSSPipeline.ScansContent 时出现新的 class 候选词才重新构建 CSS
+var styleContentExtensions = []string{".tsx", ".jsx", ".ts", ".js", ".html", ".md", ".mdx"}
+
+// tailwind class 候选词, 按引号、空白和标签符号切分
+var classCandidatePattern = regexp.MustCompile("[^\\s\"'`<>{}=;,]+")
+
+// BuildPhase 构建阶段及耗时
+type BuildPhase struct {
+	Name     string
+	Duration time.Duration
+	Skipped  bool
+}
+
+// BuildResult 一次构建的结果
+type BuildResult struct {
+	// 相对于 frontend 的变动文件
+	Changed  []string
+	Phases   []BuildPhase
+	Duration time.Duration
+}
+
+// phase 执行一个构建阶段并记录耗时
+func (r *BuildResult) phase(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.Phases = append(r.Phases, BuildPhase{Name: name, Duration: time.Since(start)})
+	return err
+}
+
+// skip 记录跳过的构建阶段
+func (r *BuildResult) skip(name string) {
+	r.Phases = append(r.Phases, BuildPhase{Name: name, Skipped: true})
+}
+
+// String 例如 sync=1ms entries=skipped css=skipped client=32ms server=18ms total=52ms
+func (r *BuildResult) String() string {
+	parts := make([]string, 0, len(r.Phases)+1)
+	for _, phase := range r.Phases {
+		if phase.Skipped {
+			parts = append(parts, phase.Name+"=skipped")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", phase.Name, phase.Duration.Round(time.Millisecond)))
+	}
+	parts = append(parts, fmt.Sprintf("total=%s", r.Duration.Round(time.Millisecond)))
+	return strings.Join(parts, " ")
+}
+
+// fileStamp 用于判断文件是否变动
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// IncrementalBuilder dev 模式的增量构建器
+// 只同步变动的文件到临时前端目录, 复用 esbuild context 增量构建, 没有新的 class 时跳过 CSS 构建
+type IncrementalBuilder struct {
+	config *BuildConfig
+
+	mu sync.Mutex
+	// 相对于 FrontendDir 的文件快照
+	files map[string]fileStamp
+	// 已经出现过的 class 候选词
+	classCandidates map[string]struct{}
+	// 上一次客户端构建的产物, 用于删除过期的带 hash 文件
+	clientOutputs []string
+	// 上一次构建失败时未完成的阶段, 下次构建时即使没有新的变动也要执行
+	pendingEntries bool
+	pendingCSS     bool
+	pendingBuild   bool
+
+	client esbuild.BuildContext
+	server esbuild.BuildContext
+}
+
+// NewIncrementalBuilder 创建增量构建器, 需要在一次完整构建之后使用
+func NewIncrementalBuilder(config *BuildConfig) *IncrementalBuilder {
+	return &IncrementalBuilder{config: config}
+}
+
+// Reset 丢弃快照和 esbuild context, 在完整构建之后调用
+func (b *IncrementalBuilder) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dispose()
+	b.files = nil
+	b.classCandidates = nil
+	b.clientOutputs = nil
+	b.pendingEntries = false
+	b.pendingCSS = false
+	b.pendingBuild = false
+}
+
+// Dispose 释放 esbuild context
+func (b *IncrementalBuilder) Dispose() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dispose()
+}
+
+func (b *IncrementalBuilder) dispose() {
+	if b.client != nil {
+		b.client.Dispose()
+		b.client = nil
+	}
+	if b.server != nil {
+		b.server.Dispose()
+		b.server = nil
+	}
+}
+
+// Rebuild 根据 frontend 目录的变动增量构建
+func (b *IncrementalBuilder) Rebuild() (*BuildResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := time.Now()
+	result := &BuildResult{}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	// 没有快照时同步并构建全部文件
+	if b.files == nil {
+		b.files = map[string]fileStamp{}
+		b.classCandidates = map[string]struct{}{}
+	}
+
+	var changed, removed []string
+	err := result.phase("sync", func() error {
+		var err error
+		changed, removed, err = b.syncFrontend()
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Changed = append(slices.Clone(changed), removed...)
+
+	if len(result.Changed) == 0 && b.client != nil && !b.pendingBuild {
+		return result, nil
+	}
+
+	// 快照已经更新, 失败的阶段需要记录下来留到下次构建
+	b.pendingEntries = b.pendingEntries || b.pagesChanged(changed, removed)
+	b.pendingCSS = b.pendingCSS || b.cssAffected(changed, removed)
+	b.pendingBuild = true
+
+	if err := result.phase("public", func() error { return b.syncPublic(changed, removed) }); err != nil {
+		return result, err
+	}
+
+	if b.pendingEntries {
+		// 入口文件在创建 context 时确定, 页面增删后需要重新创建
+		if err := result.phase("entries", b.regenerateEntries); err != nil {
+			return result, err
+		}
+		b.pendingEntries = false
+	} else {
+		result.skip("entries")
+	}
+
+	if b.pendingCSS {
+		if err := result.phase("css", b.buildCSS); err != nil {
+			return result, err
+		}
+		b.pendingCSS = false
+	} else {
+		result.skip("css")
+	}
+
+	if err := result.phase("client", b.rebuildClient); err != nil {
+		return result, err
+	}
+
+	if err := result.phase("server", b.rebuildServer); err != nil {
+		return result, err
+	}
+	b.pendingBuild = false
+
+	reloadManifest()
+
+	return result, nil
+}
+
+// Prime 在完整构建之后记录文件快照和 class 候选词, 之后的 Rebuild 只处理变动的文件
+func (b *IncrementalBuilder) Prime() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.files = map[string]fileStamp{}
+	b.classCandidates = map[string]struct{}{}
+
+	// 临时目录被清理时需要重新复制
+	if _, err := os.Stat(b.config.TmpFrontendDir); os.IsNotExist(err) {
+		if err := copyDir(b.config.FrontendDir, b.config.TmpFrontendDir); err != nil {
+			return err
+		}
+		if err := b.regenerateEntries(); err != nil {
+			return err
+		}
+		if err := b.buildCSS(); err != nil {
+			return err
+		}
+	}
+
+	err := filepath.WalkDir(b.config.FrontendDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, stamp, err := b.stamp(path, d)
+		if err != nil {
+			return err
+		}
+		b.files[rel] = stamp
+
+		if slices.Contains(styleContentExtensions, filepath.Ext(path)) {
+			b.collectClassCandidates(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 记录完整构建的客户端产物, 第一次增量构建时删除其中过期的文件, 完整构建失败时没有 metafile
+	if meta, err := readMetafile(b.config, clientMetafileName); err == nil {
+		b.clientOutputs = metafileOutputs(meta, b.config.RootDir)
+	}
+	return nil
+}
+
+func (b *IncrementalBuilder) stamp(path string, d fs.DirEntry) (string, fileStamp, error) {
+	rel, err := filepath.Rel(b.config.FrontendDir, path)
+	if err != nil {
+		return "", fileStamp{}, err
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return "", fileStamp{}, err
+	}
+
+	return rel, fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// syncFrontend 将变动的文件复制到临时前端目录, 删除已经移除的文件
+func (b *IncrementalBuilder) syncFrontend() (changed, removed []string, err error) {
+	seen := map[string]bool{}
+
+	err = filepath.WalkDir(b.config.FrontendDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, stamp, err := b.stamp(path, d)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+
+		if prev, ok := b.files[rel]; ok && prev == stamp {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(b.config.TmpFrontendDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, content, DefaultFileMode); err != nil {
+			return err
+		}
+
+		b.files[rel] = stamp
+		changed = append(changed, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for rel := range b.files {
+		if seen[rel] {
+			continue
+		}
+		delete(b.files, rel)
+		removed = append(removed, rel)
+		if err := os.Remove(filepath.Join(b.config.TmpFrontendDir, rel)); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	return changed, removed, nil
+}
+
+// pagesChanged 是否新增或删除了页面
+func (b *IncrementalBuilder) pagesChanged(changed, removed []string) bool {
+	isPage := func(rel string) bool {
+		return strings.HasPrefix(filepath.ToSlash(rel), "pages/") && (strings.HasSuffix(rel, ".tsx") || strings.HasSuffix(rel, ".jsx"))
+	}
+
+	if slices.ContainsFunc(removed, isPage) {
+		return true
+	}
+
+	// 新增的页面还没有入口文件
+	return slices.ContainsFunc(changed, func(rel string) bool {
+		if !isPage(rel) {
+			return false
+		}
+		entry := filepath.Join(b.config.ClientEntry, strings.TrimPrefix(filepath.ToSlash(rel), "pages/"))
+		_, err := os.Stat(entry)
+		return os.IsNotExist(err)
+	})
+}
+
+// regenerateEntries 重新生成入口文件并丢弃 esbuild context
+func (b *IncrementalBuilder) regenerateEntries() error {
+	for _, dir := range []string{b.config.ClientEntry, b.config.ServerEntry} {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+
+	if err := ensureDirectories(b.config.ClientEntry, b.config.ServerEntry); err != nil {
+		return err
+	}
+
+	b.dispose()
+	return generateConfigEntryFiles(b.config)
+}
+
+// cssAffected 样式文件变动, 或者变动的文件中出现了新的 class 候选词
+func (b *IncrementalBuilder) cssAffected(changed, removed []string) bool {
+	if b.config.CSS == nil {
+		return false
+	}
+
+	if slices.ContainsFunc(removed, isStyleFile) {
+		return true
+	}
+
+	if !b.config.CSS.ScansContent() {
+		return slices.ContainsFunc(changed, isStyleFile)
+	}
+
+	affected := false
+	for _, rel := range changed {
+		if isStyleFile(rel) {
+			affected = true
+			continue
+		}
+		if slices.Contains(styleContentExtensions, filepath.Ext(rel)) {
+			// 需要遍历所有文件以记录新的候选词
+			if b.collectClassCandidates(filepath.Join(b.config.FrontendDir, rel)) {
+				affected = true
+			}
+		}
+	}
+	return affected
+}
+
+func isStyleFile(rel string) bool {
+	return slices.Contains(styleExtensions, filepath.Ext(rel))
+}
+
+// collectClassCandidates 记录文件中的 class 候选词, 出现新的候选词时返回 true
+func (b *IncrementalBuilder) collectClassCandidates(path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// 读取失败时保守地认为需要重新构建
+		return true
+	}
+
+	added := false
+	for _, candidate := range classCandidatePattern.FindAllString(string(content), -1) {
+		if _, ok := b.classCandidates[candidate]; !ok {
+			b.classCandidates[candidate] = struct{}{}
+			added = true
+		}
+	}
+	return added
+}
+
+func (b *IncrementalBuilder) buildCSS() error {
+	return buildCSS(b.config)
+}
+
+// rebuildClient 增量构建客户端并更新产物清单
+func (b *IncrementalBuilder) rebuildClient() error {
+	if b.client == nil {
+		aliases, err := b.config.pathAliases()
+		if err != nil {
+			return err
+		}
+		env, err := loadBuildEnv(b.config)
+		if err != nil {
+			return err
+		}
+		options, err := clientBuildOptions(b.config.RootDir, b.config.ClientEntry, b.config.BuildDir, aliases, b.config.TmpFrontendDir, env, b.config.outputOptions())
+		if err != nil {
+			return err
+		}
+		ctx, ctxErr := esbuild.Context(options)
+		if ctxErr != nil {
+			return fmt.Errorf("create client build context: %w", esbuildError(ctxErr.Errors))
+		}
+		b.client = ctx
+	}
+
+	targets := b.config.outputOptions().targets
+	build := b.client.Rebuild()
+	targets.logWarnings("client", build.Warnings)
+	if len(build.Errors) > 0 {
+		return esbuildError(targets.annotate(build.Errors))
+	}
+
+	if err := writeClientManifest(build.Metafile, b.config.RootDir, b.config.TmpFrontendDir, b.config.BuildDir); err != nil {
+		return err
+	}
+
+	return b.removeStaleOutputs(build.Metafile, b.config.RootDir)
+}
+
+// removeStaleOutputs 删除上一次构建中已经不再使用的带 hash 产物
+func (b *IncrementalBuilder) removeStaleOutputs(metafile, workDir string) error {
+	meta, err := parseMetafile(metafile)
+	if err != nil {
+		return err
+	}
+
+	outputs := metafileOutputs(meta, workDir)
+	for _, prev := range b.clientOutputs {
+		if !slices.Contains(outputs, prev) {
+			if err := os.Remove(prev); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := removeCompressed(prev); err != nil {
+				return err
+			}
+		}
+	}
+
+	b.clientOutputs = outputs
+	return nil
+}
+
+// metafileOutputs metafile 中所有产物的绝对路径
+func metafileOutputs(meta *esbuildMetafile, workDir string) []string {
+	outputs := make([]string, 0, len(meta.Outputs))
+	for outPath := range meta.Outputs {
+		outputs = append(outputs, filepath.Join(workDir, outPath))
+	}
+	return outputs
+}
+
+func (b *IncrementalBuilder) rebuildServer() error {
+	if b.server == nil {
+		aliases, err := b.config.pathAliases()
+		if err != nil {
+			return err
+		}
+		env, err := loadBuildEnv(b.config)
+		if err != nil {
+			return err
+		}
+		options, err := serverBuildOptions(b.config.RootDir, b.config.ServerEntry, b.config.BuildServerDir, aliases, env, b.config.outputOptions())
+		if err != nil {
+			return err
+		}
+		ctx, ctxErr := esbuild.Context(options)
+		if ctxErr != nil {
+			return fmt.Errorf("create server build context: %w", esbuildError(ctxErr.Errors))
+		}
+		b.server = ctx
+	}
+
+	targets := b.config.outputOptions().serverTargets
+	build := b.server.Rebuild()
+	targets.logWarnings("server", build.Warnings)
+	if len(build.Errors) > 0 {
+		return esbuildError(targets.annotate(build.Errors))
+	}
+	return nil
+}
+
+// syncPublic 将 public 目录中变动的文件同步到构建目录, 同时删除对应的预压缩文件
+// 只处理位于 FrontendDir 中的 public 目录, 其他位置的 public 目录只在完整构建时复制
+func (b *IncrementalBuilder) syncPublic(changed, removed []string) error {
+	if !isSubPath(b.config.FrontendDir, b.config.PublicDir) {
+		return nil
+	}
+
+	prefix, err := filepath.Rel(b.config.FrontendDir, b.config.PublicDir)
+	if err != nil {
+		return err
+	}
+	publicRel := func(rel string) (string, bool) {
+		return strings.CutPrefix(filepath.ToSlash(rel), filepath.ToSlash(prefix)+"/")
+	}
+
+	for _, rel := range changed {
+		name, ok := publicRel(rel)
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(b.config.FrontendDir, rel))
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(b.config.BuildDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, content, DefaultFileMode); err != nil {
+			return err
+		}
+		// 增量构建不预压缩, 完整构建生成的压缩版本已经过期
+		if err := removeCompressed(dest); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range removed {
+		name, ok := publicRel(rel)
+		if !ok {
+			continue
+		}
+		dest := filepath.Join(b.config.BuildDir, filepath.FromSlash(name))
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := removeCompressed(dest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logBuildResult 输出每个阶段的耗时
+func logBuildResult(result *BuildResult, err error) {
+	if err != nil {
+		xlog.Error("rebuild failed", xlog.String("phases", result.String()), xlog.Err(err))
+		return
+	}
+	xlog.Info("rebuild done", xlog.Int("changed", len(result.Changed)), xlog.String("phases", result.String()))
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncPublicRemovesCompressed(t *testing.T) {
+	root := t.TempDir()
+	config, err := NewBuildConfig(root, WithTmpDir(filepath.Join(root, "tmp")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(p, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), DefaultFileMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	for _, name := range []string{"logo.svg", "old.js"} {
+		dest := filepath.Join(config.BuildDir, name)
+		write(dest, "old")
+		write(dest+".br", "old")
+		write(dest+".gz", "old")
+	}
+	write(filepath.Join(config.PublicDir, "logo.svg"), "new")
+
+	b := NewIncrementalBuilder(config)
+	if err := b.syncPublic([]string{"public/logo.svg"}, []string{"public/old.js"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logo := filepath.Join(config.BuildDir, "logo.svg")
+	if content, _ := os.ReadFile(logo); string(content) != "new" {
+		t.Errorf("logo.svg = %q, want new", content)
+	}
+	for _, p := range []string{logo + ".br", logo + ".gz", filepath.Join(config.BuildDir, "old.js"), filepath.Join(config.BuildDir, "old.js.br"), filepath.Join(config.BuildDir, "old.js.gz")} {
+		if exists(p) {
+			t.Errorf("%s should be removed", filepath.Base(p))
+		}
+	}
+}
+
+func TestPrimeRecordsClientOutputs(t *testing.T) {
+	root := t.TempDir()
+	config, err := NewBuildConfig(root, WithTmpDir(filepath.Join(root, "tmp")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, root, map[string]string{
+		"frontend/pages/Home.tsx":        "",
+		"build/assets/Home-A.js":         "old",
+		"build/assets/Home-A.js.gz":      "old",
+		"build/assets/chunk-A.js":        "shared",
+		".goreact/" + clientMetafileName: `{"outputs":{"build/assets/Home-A.js":{},"build/assets/chunk-A.js":{}}}`,
+	})
+	if err := os.MkdirAll(config.TmpFrontendDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewIncrementalBuilder(config)
+	if err := b.Prime(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.removeStaleOutputs(`{"outputs":{"build/assets/Home-B.js":{},"build/assets/chunk-A.js":{}}}`, root); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"build/assets/Home-A.js", false},
+		{"build/assets/Home-A.js.gz", false},
+		{"build/assets/chunk-A.js", true},
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(root, tt.file))
+		if exists := err == nil; exists != tt.want {
+			t.Errorf("%s exists = %v, want %v", tt.file, exists, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	"rogchap.com/v8go"
+)
+
+// 代码片段中出错行前后显示的行数
+const codeFrameContext = 2
+
+// Home.js:12:5
+var jsLocationPattern = regexp.MustCompile(`^(.+):(\d+):(\d+)$`)
+
+// DevError dev 模式下通过 HMR 通道发送给浏览器的错误, File 相对于项目根目录, Line 和 Column 从 1 开始
+type DevError struct {
+	// 错误来源: build、css、render
+	Source  string `json:"source"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	// 出错位置附近的源码
+	Frame string `json:"frame,omitempty"`
+	Stack string `json:"stack,omitempty"`
+}
+
+// devErrorsFromBuild 将构建错误转换为 DevError, esbuild 的每条错误单独显示
+func devErrorsFromBuild(err error, config *BuildConfig) []DevError {
+	var buildErr *EsbuildError
+	if errors.As(err, &buildErr) {
+		result := make([]DevError, 0, len(buildErr.Messages))
+		for _, message := range buildErr.Messages {
+			result = append(result, devErrorFromMessage(message, config))
+		}
+		return result
+	}
+
+	var cssErr *CSSCommandError
+	if errors.As(err, &cssErr) {
+		return []DevError{{Source: "css", Message: cssErr.Error(), Frame: cssErr.Output}}
+	}
+
+	return []DevError{{Source: "build", Message: err.Error()}}
+}
+
+func devErrorFromMessage(message esbuild.Message, config *BuildConfig) DevError {
+	text := message.Text
+	for _, note := range message.Notes {
+		text += "\n" + note.Text
+	}
+	devErr := DevError{Source: "build", Message: text}
+	if message.PluginName != "" {
+		devErr.Message = fmt.Sprintf("[plugin %s] %s", message.PluginName, text)
+	}
+
+	location := message.Location
+	if location == nil {
+		return devErr
+	}
+
+	file := location.File
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(config.RootDir, file)
+	}
+	file = config.sourceFile(file)
+
+	devErr.File = config.relativeFile(file)
+	devErr.Line = location.Line
+	devErr.Column = location.Column + 1
+	if content, err := os.ReadFile(file); err == nil {
+		devErr.Frame = codeFrame(string(content), 1, devErr.Line, devErr.Column)
+	} else if location.LineText != "" {
+		devErr.Frame = codeFrame(location.LineText, devErr.Line, devErr.Line, devErr.Column)
+	}
+	return devErr
+}
+
+// devErrorFromRender 将 SSR 渲染错误转换为 DevError, 通过服务端 bundle 的 source map 定位到源码
+func devErrorFromRender(component string, err error, config *BuildConfig) DevError {
+	devErr := DevError{Source: "render", Message: err.Error()}
+
+	var jsErr *v8go.JSError
+	if !errors.As(err, &jsErr) {
+		return devErr
+	}
+	devErr.Message = fmt.Sprintf("%s: %s", component, jsErr.Message)
+	devErr.Stack = jsErr.StackTrace
+
+	match := jsLocationPattern.FindStringSubmatch(jsErr.Location)
+	if match == nil {
+		return devErr
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	devErr.File, devErr.Line, devErr.Column = match[1], line, column
+
+	content, err := readServerBundle(config, match[1]+".map")
+	if err != nil {
+		return devErr
+	}
+	sourceMap, err := parseSourceMap(content)
+	if err != nil {
+		return devErr
+	}
+	// v8 的列号从 1 开始, source map 从 0 开始
+	pos, ok := sourceMap.lookup(line, column-1)
+	if !ok {
+		return devErr
+	}
+
+	file := filepath.Join(config.BuildServerDir, filepath.Dir(match[1]), filepath.FromSlash(pos.Source))
+	devErr.File = config.relativeFile(config.sourceFile(file))
+	devErr.Line = pos.Line
+	devErr.Column = pos.Column + 1
+	if pos.Content != "" {
+		devErr.Frame = codeFrame(pos.Content, 1, devErr.Line, devErr.Column)
+	}
+	return devErr
+}
+
+// sourceFile 构建时使用的 TmpFrontendDir 中的文件对应 FrontendDir 中的源码
+func (c *BuildConfig) sourceFile(file string) string {
+	if rel, err := filepath.Rel(c.TmpFrontendDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		source := filepath.Join(c.FrontendDir, rel)
+		if _, err := os.Stat(source); err == nil {
+			return source
+		}
+	}
+	return file
+}
+
+// relativeFile 项目根目录中的文件显示为相对路径
+func (c *BuildConfig) relativeFile(file string) string {
+	if rel, err := filepath.Rel(c.RootDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
+
+// codeFrame 截取 line 前后的源码, 并在 column 下方标记出错位置, first 为 content 第一行的行号
+func codeFrame(content string, first, line, column int) string {
+	lines := strings.Split(content, "\n")
+	last := first + len(lines) - 1
+	if line < first || line > last {
+		return ""
+	}
+
+	var b strings.Builder
+	start, end := max(line-codeFrameContext, first), min(line+codeFrameContext, last)
+	for i := start; i <= end; i++ {
+		text := strings.TrimRight(lines[i-first], "\r")
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s%3d | %s\n", marker, i, text)
+		if i == line && column > 0 {
+			// 保留缩进中的 tab, 让标记与出错的字符对齐
+			prefix := []rune(text)[:min(column-1, len([]rune(text)))]
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, string(prefix))
+			fmt.Fprintf(&b, "     | %s^\n", indent)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// devErrorEvent 通过 HMR 通道发送的错误事件, 没有错误时发送 clear 事件关闭浏览器中的错误提示
+func devErrorEvent(errs []DevError) string {
+	if len(errs) == 0 {
+		return `{"type":"clear"}`
+	}
+	data, _ := json.Marshal(struct {
+		Type   string     `json:"type"`
+		Errors []DevError `json:"errors"`
+	}{"error", errs})
+	return string(data)
+}
+
+// devErrorState 记录 dev 模式下当前的构建错误和渲染错误, 状态变化时通过 send 通知浏览器
+type devErrorState struct {
+	config *BuildConfig
+	send   func(event string)
+
+	mu     sync.Mutex
+	build  []DevError
+	render *DevError
+	// 渲染出错的组件
+	component string
+	// 最近一次处理的构建, 合并的构建请求会收到同一个结果
+	reported time.Time
+}
+
+// devErrors dev 模式下的错误状态, 其他模式下为 nil, 方法可以在 nil 上调用
+var devErrors *devErrorState
+
+func newDevErrorState(config *BuildConfig, send func(event string)) *devErrorState {
+	return &devErrorState{config: config, send: send}
+}
+
+// current 当前的错误, 构建错误优先, 构建失败时渲染的还是上一次的产物
+func (s *devErrorState) current() []DevError {
+	if len(s.build) > 0 {
+		return s.build
+	}
+	if s.render != nil {
+		return []DevError{*s.render}
+	}
+	return nil
+}
+
+// Event 当前错误对应的事件, 没有错误时返回空字符串, 用于新连接的浏览器
+func (s *devErrorState) Event() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errs := s.current(); len(errs) > 0 {
+		return devErrorEvent(errs)
+	}
+	return ""
+}
+
+// reportBuild 记录一次构建的结果, 构建成功时清除所有错误
+func (s *devErrorState) reportBuild(status BuildStatus) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status.StartedAt.Equal(s.reported) {
+		return
+	}
+	s.reported = status.StartedAt
+
+	if status.Success {
+		hadErrors := len(s.current()) > 0
+		s.build, s.render = nil, nil
+		if hadErrors {
+			s.send(devErrorEvent(nil))
+		}
+		return
+	}
+
+	s.build = devErrorsFromBuild(status.err, s.config)
+	s.send(devErrorEvent(s.build))
+}
+
+// reportRender 记录 SSR 渲染错误
+func (s *devErrorState) reportRender(component string, err error) {
+	if s == nil {
+		return
+	}
+	devErr := devErrorFromRender(component, err, s.config)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.render, s.component = &devErr, component
+	if len(s.build) == 0 {
+		s.send(devErrorEvent([]DevError{devErr}))
+	}
+}
+
+// renderOK 组件渲染成功时清除该组件之前的渲染错误
+func (s *devErrorState) renderOK(component string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.render == nil || s.component != component {
+		return
+	}
+	s.render = nil
+	if len(s.build) == 0 {
+		s.send(devErrorEvent(nil))
+	}
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// DefaultEnvPrefix 默认的公开环境变量前缀, 只有带该前缀的变量会注入前端代码
+const DefaultEnvPrefix = "GOREACT_PUBLIC_"
+
+// 不带前缀也可以在前端代码中使用的变量, 由构建根据模式设置
+var builtinEnvNames = []string{"NODE_ENV", "MODE", "DEV", "PROD", "SSR"}
+
+// 前端代码中对环境变量的引用: process.env.NAME、process.env["NAME"]、import.meta.env.NAME
+var envReferencePattern = regexp.MustCompile(`(process\.env|import\.meta\.env)(?:\.([A-Za-z_$][\w$]*)|\[\s*["']([^"'\]]+)["']\s*\])`)
+
+// 需要检查环境变量引用的源文件
+var envGuardFilter = `\.(tsx|ts|jsx|js|mjs)$`
+
+// envFiles 按优先级从低到高排列的 .env 文件
+//
+//	.env                 所有模式
+//	.env.local           所有模式, 本地覆盖, 不应提交
+//	.env.[mode]          例如 .env.production
+//	.env.[mode].local    例如 .env.development.local
+func (c *BuildConfig) envFiles() []string {
+	return []string{
+		filepath.Join(c.RootDir, ".env"),
+		filepath.Join(c.RootDir, ".env.local"),
+		filepath.Join(c.RootDir, ".env."+string(c.Mode)),
+		filepath.Join(c.RootDir, ".env."+string(c.Mode)+".local"),
+	}
+}
+
+// buildEnv 注入前端代码的环境变量
+type buildEnv struct {
+	mode   BuildMode
+	prefix string
+	// 带公开前缀的变量
+	public map[string]string
+	// 配置中的 Define, 原样作为 JS 表达式注入
+	define map[string]string
+}
+
+// loadBuildEnv 读取 .env 文件和进程环境变量, 进程环境变量优先于文件
+func loadBuildEnv(config *BuildConfig) (*buildEnv, error) {
+	vars := map[string]string{}
+	for _, file := range config.envFiles() {
+		values, err := readEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			vars[key] = value
+		}
+	}
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			vars[key] = value
+		}
+	}
+
+	env := &buildEnv{
+		mode:   config.Mode,
+		prefix: defaultString(config.EnvPrefix, DefaultEnvPrefix),
+		public: map[string]string{},
+		define: config.Define,
+	}
+	for key, value := range vars {
+		if strings.HasPrefix(key, env.prefix) {
+			env.public[key] = value
+		}
+	}
+	return env, nil
+}
+
+// defines 生成 esbuild 的 Define, ssr 为 true 时用于服务端 bundle
+// 客户端和服务端注入相同的公开变量, 保证 SSR 和 hydrate 的结果一致
+func (e *buildEnv) defines(ssr bool) map[string]string {
+	mode := e.mode
+	if mode == "" {
+		mode = ModeProduction
+	}
+
+	define := map[string]string{}
+	set := func(name, value string) {
+		define["process.env."+name] = value
+		define["import.meta.env."+name] = value
+	}
+
+	set("NODE_ENV", jsonString(string(mode)))
+	set("MODE", jsonString(string(mode)))
+	set("DEV", fmt.Sprint(mode == ModeDevelopment))
+	set("PROD", fmt.Sprint(mode == ModeProduction))
+	set("SSR", fmt.Sprint(ssr))
+	for key, value := range e.public {
+		set(key, jsonString(value))
+	}
+
+	for key, value := range e.define {
+		define[key] = value
+	}
+	return define
+}
+
+// allowed 前端代码是否可以引用该变量
+func (e *buildEnv) allowed(expr, name string) bool {
+	if strings.HasPrefix(name, e.prefix) || slices.Contains(builtinEnvNames, name) {
+		return true
+	}
+	_, ok := e.define[expr+"."+name]
+	return ok
+}
+
+// envGuardPlugin 客户端代码引用了非公开的环境变量时构建失败, 避免密钥被打包到浏览器
+// node_modules 中的代码不检查, 注释和字符串中出现的引用不算
+func envGuardPlugin(env *buildEnv, rootDir string) esbuild.Plugin {
+	return esbuild.Plugin{
+		Name: "env-guard",
+		Setup: func(build esbuild.PluginBuild) {
+			build.OnLoad(esbuild.OnLoadOptions{Filter: envGuardFilter}, func(args esbuild.OnLoadArgs) (esbuild.OnLoadResult, error) {
+				if strings.Contains(filepath.ToSlash(args.Path), "/node_modules/") {
+					return esbuild.OnLoadResult{}, nil
+				}
+
+				content, err := os.ReadFile(args.Path)
+				if err != nil {
+					// 交给 esbuild 报告读取错误
+					return esbuild.OnLoadResult{}, nil
+				}
+
+				var candidates []envReference
+				for _, match := range envReferencePattern.FindAllSubmatch(content, -1) {
+					ref := envReference{expr: string(match[1]), name: string(match[2])}
+					if ref.name == "" {
+						ref.name = string(match[3])
+					}
+					if !env.allowed(ref.expr, ref.name) && !slices.Contains(candidates, ref) {
+						candidates = append(candidates, ref)
+					}
+				}
+				if len(candidates) == 0 {
+					return esbuild.OnLoadResult{}, nil
+				}
+
+				var errs []esbuild.Message
+				for _, use := range findEnvReferences(args.Path, content, candidates) {
+					message := esbuild.Message{
+						Text: fmt.Sprintf("%s.%s is not public and cannot be used in client code", use.expr, use.name),
+						Notes: []esbuild.Note{{
+							Text: fmt.Sprintf("Only variables prefixed with %s are exposed to the browser. Rename it or read it on the server and pass it as props.", env.prefix),
+						}},
+					}
+					if use.start >= 0 {
+						message.Location = sourceLocation(rootDir, args.Path, content, use.start, use.end)
+					}
+					errs = append(errs, message)
+				}
+
+				// 没有错误时不返回内容, 由 esbuild 按默认方式加载
+				return esbuild.OnLoadResult{Errors: errs}, nil
+			})
+		},
+	}
+}
+
+// envReference 对环境变量的一次引用, 例如 process.env.SECRET_KEY
+type envReference struct {
+	// process.env 或 import.meta.env
+	expr string
+	name string
+}
+
+// define 对应的 esbuild Define 名称, 变量名不是合法标识符时使用 process.env["NAME"]
+func (r envReference) define() string {
+	if jsIdentifierPattern.MatchString(r.name) {
+		return r.expr + "." + r.name
+	}
+	return r.expr + "[" + jsonString(r.name) + "]"
+}
+
+// envReferenceUse 代码中实际引用变量的位置, start 和 end 为字节偏移, 无法定位时为 -1
+type envReferenceUse struct {
+	envReference
+	start, end int
+}
+
+var (
+	jsIdentifierPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+	envMarkerPattern    = regexp.MustCompile(`__goreact_env_guard_(\d+)__`)
+)
+
+// findEnvReferences 找出 candidates 中真正在代码里引用的变量
+// 正则匹配会把注释和字符串中的文本也算进去, 这里通过 esbuild 的 Define 把引用替换为标记,
+// Define 只替换表达式, 再通过 source map 将标记的位置对应回源码; 语法错误时不报告, 由 esbuild 处理
+func findEnvReferences(file string, content []byte, candidates []envReference) []envReferenceUse {
+	define := make(map[string]string, len(candidates))
+	for i, ref := range candidates {
+		define[ref.define()] = fmt.Sprintf("__goreact_env_guard_%d__", i)
+	}
+
+	result := esbuild.Transform(string(content), esbuild.TransformOptions{
+		Loader:        scriptLoader(file),
+		JSX:           esbuild.JSXPreserve,
+		LegalComments: esbuild.LegalCommentsNone,
+		Sourcemap:     esbuild.SourceMapExternal,
+		Sourcefile:    file,
+		Define:        define,
+	})
+	if len(result.Errors) > 0 {
+		return nil
+	}
+	mappings, err := parseSourceMap(result.Map)
+	if err != nil {
+		mappings = &sourceMap{}
+	}
+
+	var uses []envReferenceUse
+	for i, line := range strings.Split(string(result.Code), "\n") {
+		for _, match := range envMarkerPattern.FindAllStringSubmatchIndex(line, -1) {
+			index, _ := strconv.Atoi(line[match[2]:match[3]])
+			use := envReferenceUse{envReference: candidates[index], start: -1, end: -1}
+			if pos, ok := mappings.lookup(i+1, utf16Len(line[:match[0]])); ok {
+				if start, ok := byteOffset(content, pos.Line, pos.Column); ok {
+					use.start, use.end = start, start+len(use.expr)+1+len(use.name)
+					if loc := envReferencePattern.FindIndex(content[start:]); loc != nil && loc[0] == 0 {
+						use.end = start + loc[1]
+					}
+				}
+			}
+			uses = append(uses, use)
+		}
+	}
+
+	sort.SliceStable(uses, func(i, j int) bool { return uses[i].start < uses[j].start })
+	return uses
+}
+
+// utf16Len 字符串的 UTF-16 长度, source map 中的列号以 UTF-16 为单位
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// byteOffset 将行号 (从 1 开始) 和 UTF-16 列号 (从 0 开始) 转换为字节偏移
+func byteOffset(content []byte, line, column int) (int, bool) {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+	for column > 0 && offset < len(content) && content[offset] != '\n' {
+		r, size := utf8.DecodeRune(content[offset:])
+		column -= utf16.RuneLen(r)
+		offset += size
+	}
+	return offset, column <= 0
+}
+
+// sourceLocation esbuild 诊断信息中的源码位置, start 和 end 为字节偏移
+func sourceLocation(rootDir, file string, content []byte, start, end int) *esbuild.Location {
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := bytes.IndexByte(content[start:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(content)
+	} else {
+		lineEnd += start
+	}
+
+	if rel, err := filepath.Rel(rootDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+
+	return &esbuild.Location{
+		File:     filepath.ToSlash(file),
+		Line:     bytes.Count(content[:start], []byte("\n")) + 1,
+		Column:   start - lineStart,
+		Length:   end - start,
+		LineText: strings.TrimRight(string(content[lineStart:lineEnd]), "\r"),
+	}
+}
+
+// readEnvFile 读取 .env 文件, 文件不存在时返回空
+//
+//	# 注释
+//	GOREACT_PUBLIC_API_URL=https://api.example.com
+//	export SECRET_KEY="line1\nline2"
+//	GREETING='原样保留 $HOME' # 行尾注释
+func readEnvFile(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvName(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", file, lineNo)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// parseEnvValue 解析值: 双引号支持转义, 单引号原样保留, 不带引号时去掉行尾注释
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				if i+1 < len(value) {
+					i++
+					switch value[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 'r':
+						b.WriteByte('\r')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(value[i])
+					}
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	case strings.HasPrefix(value, "'"):
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return value[1 : end+1], nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'A' && r <= 'Z') && !(r >= 'a' && r <= 'z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
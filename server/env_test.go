@@ -0,0 +1,121 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseEnvValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      bool
+	}{
+		{in: "", want: ""},
+		{in: "plain", want: "plain"},
+		{in: "with spaces  ", want: "with spaces"},
+		{in: "value # comment", want: "value"},
+		{in: "a#b", want: "a#b"},
+		{in: `"double # kept"`, want: "double # kept"},
+		{in: `"line1\nline2\ttab\\\"q"`, want: "line1\nline2\ttab\\\"q"},
+		{in: `"closed" # comment`, want: "closed"},
+		{in: `'raw \n $HOME'`, want: `raw \n $HOME`},
+		{in: `"unterminated`, err: true},
+		{in: `'unterminated`, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseEnvValue(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseEnvValue(%q) err = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseEnvValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, ".env")
+	content := "# comment\n\nGOREACT_PUBLIC_API=https://api.example.com\nexport SECRET_KEY=\"a\\nb\"\nGREETING='hi $HOME' # trailing\n"
+	if err := os.WriteFile(file, []byte(content), DefaultFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := readEnvFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"GOREACT_PUBLIC_API": "https://api.example.com",
+		"SECRET_KEY":         "a\nb",
+		"GREETING":           "hi $HOME",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+
+	if values, err := readEnvFile(filepath.Join(dir, ".env.missing")); err != nil || values != nil {
+		t.Errorf("missing file = (%v, %v), want (nil, nil)", values, err)
+	}
+
+	if err := os.WriteFile(file, []byte("OK=1\n1BAD=2\n"), DefaultFileMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEnvFile(file); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("err = %v, want error on line 2", err)
+	}
+}
+
+func TestEnvGuardPlugin(t *testing.T) {
+	root := t.TempDir()
+	source := "// process.env.SECRET_KEY in a comment is fine\n" +
+		"const doc = \"process.env.SECRET_KEY\" + `process.env.SECRET_KEY`;\n" +
+		"const api = process.env.GOREACT_PUBLIC_API;\n" +
+		"const é = 1; const key = process.env.SECRET_KEY;\n" +
+		"const other = import.meta.env[\"OTHER-VAR\"];\n"
+	writeTestFiles(t, root, map[string]string{"src/main.ts": source})
+
+	env := &buildEnv{mode: ModeProduction, prefix: DefaultEnvPrefix, public: map[string]string{"GOREACT_PUBLIC_API": "x"}}
+	result := esbuild.Build(esbuild.BuildOptions{
+		EntryPoints:   []string{filepath.Join(root, "src/main.ts")},
+		Bundle:        true,
+		Write:         false,
+		Format:        esbuild.FormatESModule,
+		AbsWorkingDir: root,
+		LogLevel:      esbuild.LogLevelSilent,
+		Define:        env.defines(false),
+		Plugins:       []esbuild.Plugin{envGuardPlugin(env, root)},
+	})
+
+	type location struct {
+		text         string
+		line, column int
+		lineText     string
+	}
+	var got []location
+	for _, message := range result.Errors {
+		loc := location{text: message.Text}
+		if message.Location != nil {
+			loc.line, loc.column, loc.lineText = message.Location.Line, message.Location.Column, message.Location.LineText
+			if message.Location.File != "src/main.ts" {
+				t.Errorf("file = %q, want src/main.ts", message.Location.File)
+			}
+		}
+		got = append(got, loc)
+	}
+
+	want := []location{
+		{"process.env.SECRET_KEY is not public and cannot be used in client code", 4, 26, "const é = 1; const key = process.env.SECRET_KEY;"},
+		{"import.meta.env.OTHER-VAR is not public and cannot be used in client code", 5, 14, "const other = import.meta.env[\"OTHER-VAR\"];"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/daodao97/goreact/i18n"
+	"github.com/daodao97/xgo/xapp"
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+const (
+	// 约定的 404 页面组件, 对应 frontend/pages/NotFound.tsx
+	NotFoundComponent = "NotFound"
+	// 约定的错误页面组件, 对应 frontend/pages/Error.tsx
+	ErrorComponent = "Error"
+)
+
+// hasPageComponent 判断约定的页面组件是否存在
+// 优先查找 frontend/pages 下的源文件, 其次查找已构建的服务端 bundle (生产环境可能没有源码)
+func hasPageComponent(name string) bool {
+	config := GetBuildConfig()
+	for _, ext := range []string{".tsx", ".jsx"} {
+		if _, err := os.Stat(filepath.Join(config.FrontendDir, "pages", name+ext)); err == nil {
+			return true
+		}
+	}
+
+	_, err := fs.Stat(configBuildFS(config), serverBundlePath(config, name+".js"))
+	return err == nil
+}
+
+// errorPageData 生成错误页面组件的 props
+func errorPageData(c *gin.Context, status int, cause error) map[string]any {
+	key := "error.server"
+	title, message := "Internal Server Error", "Something went wrong, please try again later."
+	if status == http.StatusNotFound {
+		key = "error.not_found"
+		title, message = "Page Not Found", "The page you are looking for does not exist."
+	}
+
+	data := map[string]any{
+		"Status":  status,
+		"Title":   i18n.GetWithDefault(c, key+".title", title),
+		"Message": i18n.GetWithDefault(c, key+".message", message),
+		"Path":    c.Request.URL.Path,
+	}
+
+	// 错误详情只在开发环境暴露给页面
+	if cause != nil && xapp.IsDev() {
+		data["Error"] = cause.Error()
+	}
+
+	return data
+}
+
+// renderErrorPage 渲染约定的错误页面组件, 组件不存在时回退到内置模板
+func renderErrorPage(renderer *TemplateRenderer, c *gin.Context, status int, cause error) {
+	component := ErrorComponent
+	if status == http.StatusNotFound {
+		component = NotFoundComponent
+	}
+
+	data := errorPageData(c, status, cause)
+
+	if hasPageComponent(component) {
+		c.HTML(status, component, data)
+		return
+	}
+
+	name := "error.html"
+	if status == http.StatusNotFound {
+		name = "not_found.html"
+	}
+
+	c.Render(status, render.HTML{
+		Template: renderer.templates,
+		Name:     name,
+		Data: map[string]any{
+			"Title":        data["Title"],
+			"Message":      data["Message"],
+			"ErrorMessage": data["Error"],
+			"RequestInfo":  data["Path"],
+			"IsDev":        xapp.IsDev(),
+		},
+	})
+}
+
+// NoRouteHandler 未匹配路由时渲染 NotFound 页面
+func NoRouteHandler(renderer *TemplateRenderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderErrorPage(renderer, c, http.StatusNotFound, nil)
+	}
+}
+
+// RecoveryMiddleware 捕获 handler 中的 panic 并渲染 Error 页面
+func RecoveryMiddleware(renderer *TemplateRenderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			// 交给 net/http 处理的中断信号, 原样抛出
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			xlog.Error("panic recovered",
+				xlog.String("path", c.Request.URL.Path),
+				xlog.Any("panic", rec),
+				xlog.String("stack", string(debug.Stack())))
+
+			// CompressMiddleware 缓冲中还没有发送的部分页面可以丢弃, 改为输出错误页面
+			if w, ok := c.Writer.(interface{ discardBuffered() }); ok {
+				w.discardBuffered()
+			}
+
+			// 已经开始输出响应时无法再渲染错误页面
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			renderErrorPage(renderer, c, http.StatusInternalServerError, fmt.Errorf("%v", rec))
+			c.Abort()
+		}()
+
+		c.Next()
+	}
+}
@@ -15,147 +15,205 @@ const textEncoderPolyfill = `function TextEncoder(){} TextEncoder.prototype.enco
 
 const messageChannelPolyfill = `if(typeof MessageChannel==="undefined"){var MessageChannel=function(){this.port1={postMessage:function(msg){setTimeout(()=>{this.onmessage&&this.onmessage({data:msg})},0)},onmessage:null},this.port2={postMessage:function(msg){setTimeout(()=>{this.onmessage&&this.onmessage({data:msg})},0)},onmessage:null}}}`
 
-const processPolyfill = `var process = {env: {NODE_ENV: "production"}};`
-
-func aliasPlugin(aliases map[string]string) esbuild.Plugin {
-	return esbuild.Plugin{
-		Name: "alias-resolver",
-		Setup: func(build esbuild.PluginBuild) {
-			for alias, path := range aliases {
-				build.OnResolve(esbuild.OnResolveOptions{Filter: "^" + alias + "/"}, func(args esbuild.OnResolveArgs) (esbuild.OnResolveResult, error) {
-					// 将 @/ 替换为实际路径
-					newPath := strings.TrimPrefix(args.Path, alias+"/")
-					basePath := path + "/" + newPath
-
-					// 尝试不同的扩展名
-					extensions := []string{"", ".tsx", ".ts", ".jsx", ".js"}
-					for _, ext := range extensions {
-						fullPath := basePath + ext
-						if _, err := os.Stat(fullPath); err == nil {
-							return esbuild.OnResolveResult{Path: fullPath, External: false}, nil
-						}
-					}
-
-					// 如果找不到文件，尝试作为目录查找 index 文件
-					for _, ext := range extensions[1:] { // 跳过空扩展名
-						fullPath := basePath + "/index" + ext
-						if _, err := os.Stat(fullPath); err == nil {
-							return esbuild.OnResolveResult{Path: fullPath, External: false}, nil
-						}
-					}
-
-					fmt.Printf("路径解析失败: %s -> %s\n", args.Path, basePath)
-					// 返回原始路径，让 esbuild 继续尝试解析
-					return esbuild.OnResolveResult{Path: basePath, External: false}, nil
-				})
-			}
-		},
-	}
+// processPolyfill 服务端 bundle 中的 process, NODE_ENV 与构建模式一致
+func processPolyfill(mode BuildMode) string {
+	return fmt.Sprintf(`var process = {env: {NODE_ENV: %s}};`, jsonString(defaultString(string(mode), string(ModeProduction))))
+}
+
+// EsbuildError esbuild 构建失败, 保留原始的诊断信息, dev 模式下用于在浏览器中显示错误位置
+type EsbuildError struct {
+	Messages []esbuild.Message
+}
+
+// Error 格式化为带文件位置的诊断信息
+func (e *EsbuildError) Error() string {
+	formatted := esbuild.FormatMessages(e.Messages, esbuild.FormatMessagesOptions{Kind: esbuild.ErrorMessage})
+	return fmt.Sprintf("esbuild failed with %d error(s):\n%s", len(e.Messages), strings.TrimRight(strings.Join(formatted, ""), "\n"))
+}
+
+func esbuildError(messages []esbuild.Message) error {
+	return &EsbuildError{Messages: messages}
 }
 
 func BuildClientComponents(jsFolder, jsOutput string, aliases map[string]string, tmpFrontendDir string) error {
+	config := GetBuildConfig()
+	env, err := loadBuildEnv(config)
+	if err != nil {
+		return err
+	}
+
+	pwd, _ := os.Getwd()
+	_, err = buildClientComponents(pwd, jsFolder, jsOutput, aliasesFromMap(aliases, "aliases"), tmpFrontendDir, env, config.outputOptions())
+	return err
+}
+
+// buildClientComponents 以 rootDir 为工作目录构建客户端组件, node_modules 从 rootDir 中查找, 返回 metafile
+func buildClientComponents(rootDir, jsFolder, jsOutput string, aliases []pathAlias, tmpFrontendDir string, env *buildEnv, output outputOptions) (string, error) {
 	xlog.Debug(fmt.Sprintf("Building client Javascript, jsFolder %s => jsOutput %s", jsFolder, jsOutput))
 
+	options, err := clientBuildOptions(rootDir, jsFolder, jsOutput, aliases, tmpFrontendDir, env, output)
+	if err != nil {
+		return "", err
+	}
+
+	builds := esbuild.Build(options)
+	output.targets.logWarnings("client", builds.Warnings)
+
+	if len(builds.Errors) > 0 {
+		return "", esbuildError(output.targets.annotate(builds.Errors))
+	}
+
+	return builds.Metafile, writeClientManifest(builds.Metafile, options.AbsWorkingDir, tmpFrontendDir, jsOutput)
+}
+
+// clientBuildOptions 客户端构建参数, 完整构建和 dev 模式的增量构建共用
+func clientBuildOptions(rootDir, jsFolder, jsOutput string, aliases []pathAlias, tmpFrontendDir string, env *buildEnv, output outputOptions) (esbuild.BuildOptions, error) {
 	filesJSX, err := util.GetFiles(jsFolder, ".jsx")
 	if err != nil {
-		return err
+		return esbuild.BuildOptions{}, err
 	}
 
 	filesTSX, err := util.GetFiles(jsFolder, ".tsx")
 	if err != nil {
-		return err
+		return esbuild.BuildOptions{}, err
 	}
 
 	allFiles := append(filesJSX, filesTSX...)
 	allFiles = append(allFiles, tmpFrontendDir+"/app.js")
 
-	pwd, _ := os.Getwd()
-
-	builds := esbuild.Build(esbuild.BuildOptions{
+	options := esbuild.BuildOptions{
 		EntryPoints:    allFiles,
 		Bundle:         true,
 		Write:          true,
 		Splitting:      true,
 		AllowOverwrite: true,
+		EntryNames:     "[dir]/[name]-[hash]",
+		ChunkNames:     "chunks/[name]-[hash]",
 		AssetNames:     "[name]-[hash]",
+		Metafile:       true,
 		Outdir:         jsOutput,
+		Outbase:        tmpFrontendDir, // 嵌套页面输出到对应的子目录, 例如 app/blog/Index-[hash].js
 		Format:         esbuild.FormatESModule,
 		Platform:       esbuild.PlatformBrowser,
-		Target:         esbuild.ESNext,
 		Loader: map[string]esbuild.Loader{
 			".jsx":  esbuild.LoaderJSX,
 			".tsx":  esbuild.LoaderTSX,
 			".scss": esbuild.LoaderLocalCSS,
 		},
-		Plugins:       []esbuild.Plugin{aliasPlugin(aliases)},
-		NodePaths:     []string{filepath.Join(pwd, "node_modules")},
-		AbsWorkingDir: pwd,
-	})
+		Define:        env.defines(false),
+		Plugins:       []esbuild.Plugin{aliasPlugin(aliases), envGuardPlugin(env, rootDir)},
+		NodePaths:     []string{filepath.Join(rootDir, "node_modules")},
+		AbsWorkingDir: rootDir,
+	}
+	if output.refresh != nil {
+		options.Plugins = append(options.Plugins, refreshPlugin(output.refresh))
+	}
+	output.applyClient(&options)
+	return options, nil
+}
 
-	if len(builds.Errors) > 0 {
-		return fmt.Errorf("error on esbuild: %v", builds.Errors)
+// writeClientManifest 根据 metafile 生成并写入客户端产物清单
+func writeClientManifest(metafile, workDir, entryRoot, jsOutput string) error {
+	meta, err := parseMetafile(metafile)
+	if err != nil {
+		return err
+	}
+
+	outDir, err := filepath.Abs(jsOutput)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := newAssetManifest(meta, workDir, entryRoot, outDir)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return writeManifest(outDir, manifest)
 }
 
 func BuildServerComponents(jsFolder, jsOutput string, aliases map[string]string) (map[string]string, error) {
+	config := GetBuildConfig()
+	env, err := loadBuildEnv(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pwd, _ := os.Getwd()
+	result, _, err := buildServerComponents(pwd, jsFolder, jsOutput, aliasesFromMap(aliases, "aliases"), env, config.outputOptions())
+	return result, err
+}
+
+// buildServerComponents 以 rootDir 为工作目录构建服务端组件, 同时返回 metafile
+func buildServerComponents(rootDir, jsFolder, jsOutput string, aliases []pathAlias, env *buildEnv, output outputOptions) (map[string]string, string, error) {
 	result := map[string]string{}
 
+	options, err := serverBuildOptions(rootDir, jsFolder, jsOutput, aliases, env, output)
+	if err != nil {
+		return result, "", err
+	}
+
+	builds := esbuild.Build(options)
+	output.serverTargets.logWarnings("server", builds.Warnings)
+
+	if len(builds.Errors) > 0 {
+		return result, "", esbuildError(output.serverTargets.annotate(builds.Errors))
+	}
+
+	for _, file := range builds.OutputFiles {
+		// 开发模式下的 source map 只用于定位 SSR 错误
+		if strings.HasSuffix(file.Path, ".map") {
+			continue
+		}
+		if strings.Contains(file.Path, jsOutput) {
+			paths := strings.Split(file.Path, jsOutput)
+			path := ""
+			if len(paths) >= 2 {
+				path = strings.Join(paths[1:], "")
+			}
+			result[path] = string(file.Contents)
+		}
+	}
+
+	return result, builds.Metafile, nil
+}
+
+// serverBuildOptions 服务端构建参数, 完整构建和 dev 模式的增量构建共用
+func serverBuildOptions(rootDir, jsFolder, jsOutput string, aliases []pathAlias, env *buildEnv, output outputOptions) (esbuild.BuildOptions, error) {
 	filesJSX, err := util.GetFiles(jsFolder, ".jsx")
 	if err != nil {
-		return result, err
+		return esbuild.BuildOptions{}, err
 	}
 
 	filesTSX, err := util.GetFiles(jsFolder, ".tsx")
 	if err != nil {
-		return result, err
+		return esbuild.BuildOptions{}, err
 	}
 
-	// filesJS, err := GetFiles(jsFolder, ".js")
-	// if err != nil {
-	// 	return result, err
-	// }
-
 	allFiles := append(filesJSX, filesTSX...)
 
-	pwd, _ := os.Getwd()
-
-	builds := esbuild.Build(esbuild.BuildOptions{
+	options := esbuild.BuildOptions{
 		EntryPoints: allFiles,
 		Bundle:      true,
 		Write:       true,
+		Metafile:    true,
 		Outdir:      jsOutput,
+		Outbase:     jsFolder, // 嵌套页面输出到对应的子目录, 例如 blog/Index.js, 与 c.HTML 中的组件名一致
 		Format:      esbuild.FormatESModule,
 		Platform:    esbuild.PlatformBrowser,
-		Target:      esbuild.ESNext,
 		Banner: map[string]string{
-			"js": processPolyfill + messageChannelPolyfill + textEncoderPolyfill,
+			"js": processPolyfill(env.mode) + messageChannelPolyfill + textEncoderPolyfill,
 		},
 		Loader: map[string]esbuild.Loader{
 			".jsx":  esbuild.LoaderJSX,
 			".tsx":  esbuild.LoaderTSX,
 			".scss": esbuild.LoaderLocalCSS,
 		},
+		Define:        env.defines(true),
 		Plugins:       []esbuild.Plugin{aliasPlugin(aliases)},
-		NodePaths:     []string{filepath.Join(pwd, "node_modules")},
-		AbsWorkingDir: pwd,
-	})
-
-	if len(builds.Errors) > 0 {
-		return result, fmt.Errorf("error on esbuild: %v", builds.Errors)
-	}
-
-	for _, file := range builds.OutputFiles {
-		if strings.Contains(file.Path, jsOutput) {
-			paths := strings.Split(file.Path, jsOutput)
-			path := ""
-			if len(paths) >= 2 {
-				path = strings.Join(paths[1:], "")
-			}
-			result[path] = string(file.Contents)
-		}
+		NodePaths:     []string{filepath.Join(rootDir, "node_modules")},
+		AbsWorkingDir: rootDir,
 	}
-
-	return result, nil
+	output.applyServer(&options)
+	return options, nil
 }
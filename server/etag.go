@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const cachePolicyKey = "goreact_cache_policy"
+
+// CachePolicy 页面的 HTTP 缓存策略
+type CachePolicy struct {
+	Public               bool
+	Private              bool
+	NoCache              bool
+	NoStore              bool
+	MustRevalidate       bool
+	Immutable            bool
+	MaxAge               time.Duration
+	SMaxAge              time.Duration
+	StaleWhileRevalidate time.Duration
+	// 响应随这些请求头变化, 例如 Cookie、Accept-Language
+	Vary []string
+	// 关闭 ETag 和条件请求
+	DisableETag bool
+}
+
+// CacheControl 生成 Cache-Control 响应头
+func (p CachePolicy) CacheControl() string {
+	var directives []string
+	add := func(ok bool, directive string) {
+		if ok {
+			directives = append(directives, directive)
+		}
+	}
+	seconds := func(d time.Duration) string {
+		return fmt.Sprintf("%d", int64(d/time.Second))
+	}
+
+	add(p.Public, "public")
+	add(p.Private, "private")
+	add(p.NoCache, "no-cache")
+	add(p.NoStore, "no-store")
+	add(p.MaxAge > 0, "max-age="+seconds(p.MaxAge))
+	add(p.SMaxAge > 0, "s-maxage="+seconds(p.SMaxAge))
+	add(p.StaleWhileRevalidate > 0, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	add(p.MustRevalidate, "must-revalidate")
+	add(p.Immutable, "immutable")
+
+	return strings.Join(directives, ", ")
+}
+
+// WithCachePolicy 为路由声明缓存策略
+//
+//	r.GET("/blog/:slug", server.WithCachePolicy(server.CachePolicy{Public: true, MaxAge: time.Minute}), handler)
+func WithCachePolicy(policy CachePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(cachePolicyKey, &policy)
+		c.Next()
+	}
+}
+
+// GetCachePolicy 获取当前路由声明的缓存策略
+func GetCachePolicy(c *gin.Context) *CachePolicy {
+	if v, ok := c.Get(cachePolicyKey); ok {
+		if policy, ok := v.(*CachePolicy); ok {
+			return policy
+		}
+	}
+	return nil
+}
+
+// applyCachePolicy 输出 Cache-Control 和 Vary
+func applyCachePolicy(w http.ResponseWriter, policy *CachePolicy) {
+	if policy == nil {
+		return
+	}
+
+	header := w.Header()
+	if cacheControl := policy.CacheControl(); cacheControl != "" && header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", cacheControl)
+	}
+	for _, vary := range policy.Vary {
+		header.Add("Vary", vary)
+	}
+}
+
+// strongETag 根据最终 html 计算强 ETag
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// etagMatches 判断 If-None-Match 是否命中
+// 压缩中间件会给 ETag 加上编码后缀, 比较时忽略
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		for _, enc := range []string{encodingBrotli, encodingGzip} {
+			candidate = strings.Replace(candidate, "-"+enc+`"`, `"`, 1)
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,376 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// dev 服务器中的 React Fast Refresh, 组件变动时替换模块并保留组件状态
+//
+//  1. 客户端开发构建中, refreshPlugin 在每个前端模块末尾追加登记代码, 把模块导入的命名空间登记到 window.__GOREACT_HMR__
+//  2. 增量构建后通过 HMR 通道发送 update 事件, 包含变动的模块和新的地址 /hmr/module/<id>
+//  3. /hmr/module/<id> 单独打包该模块, 其中的 import 都从登记表中读取, 与页面共用同一份 React 和其他模块
+//  4. 浏览器中的 HMR 客户端从变动的模块向上查找只导出组件的模块 (refresh boundary),
+//     重新执行后调用 performReactRefresh, 找不到时刷新页面
+//
+// 只有模块导出的组件会登记到 React Refresh, 模块内未导出的组件没有登记,
+// 所在模块更新后这些组件会重新挂载, 状态不会保留
+
+const (
+	// 更新模块的访问前缀, 其后为模块 id
+	refreshModulePath = "/hmr/module/"
+	// 更新模块中从登记表读取的依赖
+	refreshNamespace = "goreact-hmr"
+)
+
+// 打包到 HMR 客户端之前的 React Refresh runtime, 需要在 react-dom 加载之前执行
+const refreshRuntimeEntry = `import RefreshRuntime from "react-refresh/runtime";
+RefreshRuntime.injectIntoGlobalHook(window);
+window.__goreactRefresh = RefreshRuntime;
+`
+
+// 可以替换的模块
+var refreshScriptExtensions = []string{".tsx", ".jsx", ".ts", ".js", ".mjs"}
+
+var (
+	// esbuild 转换后的 import/export 语句, 类型导入已经被删除
+	importSpecifierPattern = regexp.MustCompile(`(?m)^(?:import\s*|(?:import|export)\b[^"';]*?\bfrom\s*)["']([^"'\n]+)["']`)
+	// 组件中调用的 hook, 变动时不保留状态
+	hookCallPattern = regexp.MustCompile(`\buse[A-Z0-9]\w*`)
+)
+
+// refreshResolve 作为 PluginData 传给 build.Resolve, 表示按默认方式解析, 不从登记表读取
+type refreshResolve struct{}
+
+// refreshModules 模块 id 与文件的对应关系
+// 前端源码和临时前端目录中的文件 id 相对于各自的目录, 两者的目录结构相同, 其他文件相对于项目根目录
+type refreshModules struct {
+	rootDir        string
+	frontendDir    string
+	tmpFrontendDir string
+}
+
+func (m *refreshModules) id(path string) string {
+	for _, dir := range []string{m.frontendDir, m.tmpFrontendDir, m.rootDir} {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// file 模块 id 对应的前端文件, 优先使用源码目录
+func (m *refreshModules) file(id string) (string, error) {
+	rel := filepath.FromSlash(id)
+	if !filepath.IsLocal(rel) || !slices.Contains(refreshScriptExtensions, filepath.Ext(rel)) {
+		return "", fmt.Errorf("invalid module %q", id)
+	}
+	for _, dir := range []string{m.frontendDir, m.tmpFrontendDir} {
+		file := filepath.Join(dir, rel)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("module %q not found", id)
+}
+
+// instrumented 是否是需要登记的前端模块, node_modules 中的代码不处理
+func (m *refreshModules) instrumented(path string) bool {
+	if !slices.Contains(refreshScriptExtensions, filepath.Ext(path)) || strings.Contains(filepath.ToSlash(path), "/node_modules/") {
+		return false
+	}
+	return isSubPath(m.frontendDir, path) || isSubPath(m.tmpFrontendDir, path)
+}
+
+// url 模块更新的地址, timestamp 避免浏览器复用已经加载的模块
+func (m *refreshModules) url(id string, timestamp int64) string {
+	return fmt.Sprintf("%s?t=%d", (&url.URL{Path: refreshModulePath + id}).EscapedPath(), timestamp)
+}
+
+// refreshPlugin 在前端模块末尾登记模块导入的命名空间和 hook 签名
+func refreshPlugin(m *refreshModules) esbuild.Plugin {
+	type cached struct {
+		source string
+		footer string
+	}
+	var (
+		mu    sync.Mutex
+		cache = map[string]cached{}
+	)
+
+	return esbuild.Plugin{
+		Name: "react-refresh",
+		Setup: func(build esbuild.PluginBuild) {
+			build.OnLoad(esbuild.OnLoadOptions{Filter: `\.(tsx|jsx|ts|js|mjs)$`, Namespace: "file"}, func(args esbuild.OnLoadArgs) (esbuild.OnLoadResult, error) {
+				if !m.instrumented(args.Path) {
+					return esbuild.OnLoadResult{}, nil
+				}
+
+				content, err := os.ReadFile(args.Path)
+				if err != nil {
+					// 交给 esbuild 报告读取错误
+					return esbuild.OnLoadResult{}, nil
+				}
+				source := string(content)
+
+				mu.Lock()
+				entry, ok := cache[args.Path]
+				mu.Unlock()
+				if !ok || entry.source != source {
+					entry = cached{source: source, footer: refreshFooter(build, m, args.Path, source)}
+					mu.Lock()
+					cache[args.Path] = entry
+					mu.Unlock()
+				}
+
+				contents := source + "\n" + entry.footer
+				return esbuild.OnLoadResult{
+					Contents:   &contents,
+					ResolveDir: filepath.Dir(args.Path),
+					Loader:     scriptLoader(args.Path),
+				}, nil
+			})
+		},
+	}
+}
+
+// refreshFooter 生成模块末尾的登记代码, 语法错误时不登记, 由构建报告错误
+func refreshFooter(build esbuild.PluginBuild, m *refreshModules, path, source string) string {
+	transformed := esbuild.Transform(source, esbuild.TransformOptions{
+		Loader:     scriptLoader(path),
+		Sourcefile: path,
+	})
+	if len(transformed.Errors) > 0 {
+		return ""
+	}
+
+	var specifiers []string
+	for _, match := range importSpecifierPattern.FindAllStringSubmatch(string(transformed.Code), -1) {
+		if !slices.Contains(specifiers, match[1]) {
+			specifiers = append(specifiers, match[1])
+		}
+	}
+	// tsconfig 使用自动 JSX runtime 时 esbuild 会导入 react/jsx-runtime
+	if ext := filepath.Ext(path); ext == ".tsx" || ext == ".jsx" {
+		specifiers = append(specifiers, "react/jsx-runtime", "react/jsx-dev-runtime")
+	}
+
+	var imports, deps []string
+	for _, specifier := range specifiers {
+		result := build.Resolve(specifier, esbuild.ResolveOptions{
+			Importer:   path,
+			ResolveDir: filepath.Dir(path),
+			Kind:       esbuild.ResolveJSImportStatement,
+			PluginData: refreshResolve{},
+		})
+		if len(result.Errors) > 0 || result.External || (result.Namespace != "" && result.Namespace != "file") {
+			continue
+		}
+
+		name := fmt.Sprintf("__goreact_hmr_%d", len(deps))
+		imports = append(imports, fmt.Sprintf("import * as %s from %s;", name, jsonString(specifier)))
+		deps = append(deps, fmt.Sprintf("[%s, %s]", jsonString(m.id(result.Path)), name))
+	}
+
+	return fmt.Sprintf("%s\nif (typeof window !== \"undefined\" && window.__GOREACT_HMR__) window.__GOREACT_HMR__.register(%s, %s, [%s]);\n",
+		strings.Join(imports, "\n"), jsonString(m.id(path)), jsonString(hookSignature(source)), strings.Join(deps, ", "))
+}
+
+// hookSignature 模块中 hook 调用的签名, hook 增减或调整顺序后组件重新挂载, 避免 hook 状态错位
+func hookSignature(source string) string {
+	sum := sha256.Sum256([]byte(strings.Join(hookCallPattern.FindAllString(source, -1), ",")))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+func scriptLoader(path string) esbuild.Loader {
+	switch filepath.Ext(path) {
+	case ".tsx":
+		return esbuild.LoaderTSX
+	case ".jsx":
+		return esbuild.LoaderJSX
+	case ".ts":
+		return esbuild.LoaderTS
+	}
+	return esbuild.LoaderJS
+}
+
+// refreshRegistryPlugin 更新模块中除自身以外的 import 都从页面的登记表中读取
+func refreshRegistryPlugin(m *refreshModules) esbuild.Plugin {
+	return esbuild.Plugin{
+		Name: "react-refresh-registry",
+		Setup: func(build esbuild.PluginBuild) {
+			build.OnResolve(esbuild.OnResolveOptions{Filter: ".*"}, func(args esbuild.OnResolveArgs) (esbuild.OnResolveResult, error) {
+				if args.Kind == esbuild.ResolveEntryPoint || args.PluginData == (refreshResolve{}) {
+					return esbuild.OnResolveResult{}, nil
+				}
+
+				result := build.Resolve(args.Path, esbuild.ResolveOptions{
+					Importer:   args.Importer,
+					Namespace:  args.Namespace,
+					ResolveDir: args.ResolveDir,
+					Kind:       args.Kind,
+					PluginData: refreshResolve{},
+				})
+				if len(result.Errors) > 0 {
+					return esbuild.OnResolveResult{Errors: result.Errors}, nil
+				}
+				if result.External {
+					return esbuild.OnResolveResult{Path: result.Path, External: true}, nil
+				}
+				return esbuild.OnResolveResult{Path: m.id(result.Path), Namespace: refreshNamespace}, nil
+			})
+
+			build.OnLoad(esbuild.OnLoadOptions{Filter: ".*", Namespace: refreshNamespace}, func(args esbuild.OnLoadArgs) (esbuild.OnLoadResult, error) {
+				contents := fmt.Sprintf("module.exports = window.__GOREACT_HMR__.require(%s);", jsonString(args.Path))
+				return esbuild.OnLoadResult{Contents: &contents, Loader: esbuild.LoaderJS}, nil
+			})
+		},
+	}
+}
+
+// refreshModules 开启 Fast Refresh 时的模块对应关系
+func (c *BuildConfig) refreshModules() *refreshModules {
+	return &refreshModules{rootDir: c.RootDir, frontendDir: c.FrontendDir, tmpFrontendDir: c.TmpFrontendDir}
+}
+
+// buildRefreshModule 打包单个模块的更新, 使用与页面相同的客户端构建参数
+func buildRefreshModule(config *BuildConfig, id string) ([]byte, error) {
+	output := config.outputOptions()
+	if output.refresh == nil {
+		return nil, errors.New("fast refresh is disabled")
+	}
+
+	file, err := output.refresh.file(id)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := config.pathAliases()
+	if err != nil {
+		return nil, err
+	}
+	env, err := loadBuildEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	options, err := clientBuildOptions(config.RootDir, config.ClientEntry, config.BuildDir, aliases, config.TmpFrontendDir, env, output)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只输出这一个模块, 依赖从登记表中读取
+	options.EntryPoints = []string{file}
+	options.Plugins = append([]esbuild.Plugin{refreshRegistryPlugin(output.refresh)}, options.Plugins...)
+	options.Write = false
+	options.Splitting = false
+	options.Metafile = false
+	options.Outdir = ""
+	options.Outbase = ""
+	options.EntryNames = ""
+	options.Sourcemap = esbuild.SourceMapInline
+
+	result := esbuild.Build(options)
+	if len(result.Errors) > 0 {
+		return nil, esbuildError(output.targets.annotate(result.Errors))
+	}
+	for _, file := range result.OutputFiles {
+		if !strings.HasSuffix(file.Path, ".map") {
+			return file.Contents, nil
+		}
+	}
+	return nil, fmt.Errorf("module %q has no output", id)
+}
+
+// buildRefreshRuntime 打包项目中安装的 react-refresh, 没有安装时返回错误
+func buildRefreshRuntime(config *BuildConfig) ([]byte, error) {
+	result := esbuild.Build(esbuild.BuildOptions{
+		Stdin: &esbuild.StdinOptions{
+			Contents:   refreshRuntimeEntry,
+			ResolveDir: config.RootDir,
+			Sourcefile: "react-refresh-runtime.js",
+		},
+		Bundle:        true,
+		Write:         false,
+		Format:        esbuild.FormatIIFE,
+		Platform:      esbuild.PlatformBrowser,
+		Define:        map[string]string{"process.env.NODE_ENV": jsonString(string(ModeDevelopment))},
+		NodePaths:     []string{filepath.Join(config.RootDir, "node_modules")},
+		AbsWorkingDir: config.RootDir,
+	})
+	if len(result.Errors) > 0 {
+		return nil, esbuildError(result.Errors)
+	}
+	return result.OutputFiles[0].Contents, nil
+}
+
+// refreshUpdate 通过 HMR 通道发送的模块更新
+type refreshUpdate struct {
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	Modules   []refreshModule `json:"modules"`
+	// 地址变化的样式表, 旧地址到新地址
+	Styles map[string]string `json:"styles,omitempty"`
+}
+
+type refreshModule struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// refreshEvent 增量构建对应的模块更新, 不能替换模块时返回 false, 由浏览器刷新页面
+// 删除文件、public 目录和 CSS module 的变动, 以及完整构建都需要刷新页面
+func refreshEvent(config *BuildConfig, status BuildStatus, before, after *AssetManifest) (string, bool) {
+	output := config.outputOptions()
+	if output.refresh == nil || status.Full {
+		return "", false
+	}
+
+	update := refreshUpdate{Type: "update", Timestamp: status.StartedAt.UnixMilli(), Styles: styleUpdates(before, after)}
+	for _, rel := range status.Changed {
+		file := filepath.Join(config.FrontendDir, rel)
+		if _, err := os.Stat(file); err != nil || isSubPath(config.PublicDir, file) {
+			return "", false
+		}
+
+		switch {
+		case slices.Contains(refreshScriptExtensions, filepath.Ext(rel)):
+			id := filepath.ToSlash(rel)
+			update.Modules = append(update.Modules, refreshModule{ID: id, URL: output.refresh.url(id, update.Timestamp)})
+		case filepath.Ext(rel) == ".css" && !strings.HasSuffix(rel, ".module.css"):
+			// 全局样式通过替换样式表更新
+		default:
+			return "", false
+		}
+	}
+
+	data, _ := json.Marshal(update)
+	return string(data), true
+}
+
+// styleUpdates 构建前后地址变化的样式表
+func styleUpdates(before, after *AssetManifest) map[string]string {
+	styles := map[string]string{}
+	for name, entry := range after.Entries {
+		prev, ok := before.Entries[name]
+		if !ok {
+			continue
+		}
+		for i, css := range entry.CSS {
+			if i < len(prev.CSS) && prev.CSS[i] != css {
+				styles[assetsURLPrefix+prev.CSS[i]] = assetsURLPrefix + css
+			}
+		}
+	}
+	return styles
+}
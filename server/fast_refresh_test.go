@@ -0,0 +1,285 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestImportSpecifierPattern(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"side effect", `import "./app.css";`, []string{"./app.css"}},
+		{"side effect without space", `import"./polyfill";`, []string{"./polyfill"}},
+		{"default", `import React from "react";`, []string{"react"}},
+		{"named", "import { useState, useEffect } from 'react';", []string{"react"}},
+		{"namespace", `import * as utils from "../utils";`, []string{"../utils"}},
+		{"multiline named", "import {\n  a,\n  b\n} from \"./mod\";", []string{"./mod"}},
+		{"re-export", `export { default } from "./Button";`, []string{"./Button"}},
+		{"re-export all", `export * from "./types";`, []string{"./types"}},
+		{"local export", `export const from = "x";`, nil},
+		{"dynamic import", `const Page = import("./Page");`, nil},
+		{"string in code", `console.log("import x from 'y'");`, nil},
+		{"indented", `  import x from "./x";`, nil},
+		{
+			"several",
+			"import React from \"react\";\nimport { Button } from \"@/components/Button\";\nexport * from \"./hooks\";\n",
+			[]string{"react", "@/components/Button", "./hooks"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, match := range importSpecifierPattern.FindAllStringSubmatch(tt.source, -1) {
+				got = append(got, match[1])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("specifiers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHookSignature(t *testing.T) {
+	base := hookSignature("const [a] = useState(0); useEffect(() => {}, []);")
+
+	tests := []struct {
+		name   string
+		source string
+		same   bool
+	}{
+		{"same hooks", "function A() { const [b] = useState(1); useEffect(f); }", true},
+		{"non hook calls", "const [a] = useState(0); user(); useless(); useEffect(() => {}, []);", true},
+		{"reordered", "useEffect(() => {}, []); const [a] = useState(0);", false},
+		{"added", "const [a] = useState(0); useEffect(() => {}, []); useMemo(f, []);", false},
+		{"removed", "const [a] = useState(0);", false},
+		{"custom hook", "const [a] = useState(0); useEffect(() => {}, []); use2D();", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hookSignature(tt.source)
+			if len(got) != 16 {
+				t.Errorf("signature %q length = %d, want 16", got, len(got))
+			}
+			if same := got == base; same != tt.same {
+				t.Errorf("signature %q same as %q = %v, want %v", got, base, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestRefreshModulesID(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "app")
+	m := &refreshModules{
+		rootDir:        root,
+		frontendDir:    filepath.Join(root, "frontend"),
+		tmpFrontendDir: filepath.Join(root, "build", ".frontend"),
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"frontend", filepath.Join(root, "frontend", "pages", "Home.tsx"), "pages/Home.tsx"},
+		{"tmp frontend", filepath.Join(root, "build", ".frontend", "entry.tsx"), "entry.tsx"},
+		{"root", filepath.Join(root, "shared", "util.ts"), "shared/util.ts"},
+		{"outside root", filepath.Join(filepath.Dir(root), "other", "x.ts"), filepath.ToSlash(filepath.Join(filepath.Dir(root), "other", "x.ts"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.id(tt.path); got != tt.want {
+				t.Errorf("id(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshModulesFile(t *testing.T) {
+	root := t.TempDir()
+	m := &refreshModules{
+		rootDir:        root,
+		frontendDir:    filepath.Join(root, "frontend"),
+		tmpFrontendDir: filepath.Join(root, "build", ".frontend"),
+	}
+	writeTestFiles(t, root, map[string]string{
+		"frontend/pages/Home.tsx":     "",
+		"frontend/entry.tsx":          "",
+		"frontend/app.css":            "",
+		"build/.frontend/entry.tsx":   "",
+		"build/.frontend/pages.ts":    "",
+		"secret.ts":                   "",
+		"frontend/node_modules/x.mjs": "",
+	})
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{"frontend", "pages/Home.tsx", filepath.Join(root, "frontend", "pages", "Home.tsx"), false},
+		{"prefer frontend", "entry.tsx", filepath.Join(root, "frontend", "entry.tsx"), false},
+		{"tmp frontend", "pages.ts", filepath.Join(root, "build", ".frontend", "pages.ts"), false},
+		{"mjs", "node_modules/x.mjs", filepath.Join(root, "frontend", "node_modules", "x.mjs"), false},
+		{"missing", "pages/About.tsx", "", true},
+		{"css", "app.css", "", true},
+		{"no extension", "pages/Home", "", true},
+		{"parent", "../secret.ts", "", true},
+		{"nested parent", "pages/../../secret.ts", "", true},
+		{"absolute", filepath.ToSlash(filepath.Join(root, "secret.ts")), "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.file(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("file(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("file(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStyleUpdates(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]*ManifestEntry
+		after  map[string]*ManifestEntry
+		want   map[string]string
+	}{
+		{
+			"unchanged",
+			map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css"}}},
+			map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css"}}},
+			map[string]string{},
+		},
+		{
+			"changed",
+			map[string]*ManifestEntry{"Home": {CSS: []string{"chunk-A.css", "Home-A.css"}}},
+			map[string]*ManifestEntry{"Home": {CSS: []string{"chunk-A.css", "Home-B.css"}}},
+			map[string]string{"/assets/Home-A.css": "/assets/Home-B.css"},
+		},
+		{
+			"shared across entries",
+			map[string]*ManifestEntry{"Home": {CSS: []string{"app-A.css"}}, "About": {CSS: []string{"app-A.css"}}},
+			map[string]*ManifestEntry{"Home": {CSS: []string{"app-B.css"}}, "About": {CSS: []string{"app-B.css"}}},
+			map[string]string{"/assets/app-A.css": "/assets/app-B.css"},
+		},
+		{
+			"added stylesheet",
+			map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css"}}},
+			map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css", "extra-A.css"}}},
+			map[string]string{},
+		},
+		{
+			"new entry",
+			map[string]*ManifestEntry{},
+			map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css"}}},
+			map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := styleUpdates(&AssetManifest{Entries: tt.before}, &AssetManifest{Entries: tt.after})
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("styleUpdates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshEvent(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"frontend/pages/Home.tsx":       "",
+		"frontend/components/Button.ts": "",
+		"frontend/app.css":              "",
+		"frontend/Button.module.css":    "",
+		"frontend/data.json":            "",
+		"frontend/public/logo.js":       "",
+	})
+	config := &BuildConfig{
+		RootDir:        root,
+		FrontendDir:    filepath.Join(root, "frontend"),
+		TmpFrontendDir: filepath.Join(root, "build", ".frontend"),
+		PublicDir:      filepath.Join(root, "frontend", "public"),
+		Mode:           ModeDevelopment,
+		fastRefresh:    true,
+	}
+	startedAt := time.UnixMilli(1700000000000)
+	before := &AssetManifest{Entries: map[string]*ManifestEntry{"Home": {CSS: []string{"Home-A.css"}}}}
+	after := &AssetManifest{Entries: map[string]*ManifestEntry{"Home": {CSS: []string{"Home-B.css"}}}}
+
+	tests := []struct {
+		name        string
+		mode        BuildMode
+		fastRefresh bool
+		status      BuildStatus
+		wantOK      bool
+		wantModules []string
+	}{
+		{"script", ModeDevelopment, true, BuildStatus{Changed: []string{"pages/Home.tsx"}}, true, []string{"pages/Home.tsx"}},
+		{"several scripts", ModeDevelopment, true, BuildStatus{Changed: []string{"pages/Home.tsx", "components/Button.ts"}}, true, []string{"pages/Home.tsx", "components/Button.ts"}},
+		{"global css", ModeDevelopment, true, BuildStatus{Changed: []string{"app.css"}}, true, nil},
+		{"css module", ModeDevelopment, true, BuildStatus{Changed: []string{"Button.module.css"}}, false, nil},
+		{"other type", ModeDevelopment, true, BuildStatus{Changed: []string{"data.json"}}, false, nil},
+		{"deleted", ModeDevelopment, true, BuildStatus{Changed: []string{"pages/About.tsx"}}, false, nil},
+		{"deleted with script", ModeDevelopment, true, BuildStatus{Changed: []string{"pages/Home.tsx", "pages/About.tsx"}}, false, nil},
+		{"public dir", ModeDevelopment, true, BuildStatus{Changed: []string{"public/logo.js"}}, false, nil},
+		{"full build", ModeDevelopment, true, BuildStatus{Full: true, Changed: []string{"pages/Home.tsx"}}, false, nil},
+		{"fast refresh disabled", ModeDevelopment, false, BuildStatus{Changed: []string{"pages/Home.tsx"}}, false, nil},
+		{"production", ModeProduction, true, BuildStatus{Changed: []string{"pages/Home.tsx"}}, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *config
+			c.Mode = tt.mode
+			c.fastRefresh = tt.fastRefresh
+			tt.status.StartedAt = startedAt
+
+			data, ok := refreshEvent(&c, tt.status, before, after)
+			if ok != tt.wantOK {
+				t.Fatalf("refreshEvent ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			var update refreshUpdate
+			if err := json.Unmarshal([]byte(data), &update); err != nil {
+				t.Fatal(err)
+			}
+			if update.Type != "update" || update.Timestamp != startedAt.UnixMilli() {
+				t.Errorf("update = %+v", update)
+			}
+			var ids []string
+			for _, module := range update.Modules {
+				ids = append(ids, module.ID)
+				if want := "/hmr/module/" + module.ID + "?t=1700000000000"; module.URL != want {
+					t.Errorf("module url = %q, want %q", module.URL, want)
+				}
+			}
+			if !slices.Equal(ids, tt.wantModules) {
+				t.Errorf("modules = %q, want %q", ids, tt.wantModules)
+			}
+			if got := update.Styles["/assets/Home-A.css"]; got != "/assets/Home-B.css" {
+				t.Errorf("styles = %v", update.Styles)
+			}
+		})
+	}
+}
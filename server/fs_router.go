@@ -0,0 +1,338 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/daodao97/goreact/i18n"
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPageNotFound loader 返回该错误时渲染 NotFound 页面
+var ErrPageNotFound = errors.New("page not found")
+
+// PageLoader 页面数据加载函数, 返回值作为组件的 props
+type PageLoader func(c *gin.Context) (any, error)
+
+// 路由段的类型, 排序时静态段优先, 其次是参数段, 最后是通配段
+const (
+	segmentStatic = iota
+	segmentParam
+	segmentCatchAll
+)
+
+// PageRoute 由 frontend/pages 中的页面生成的路由
+//
+//	Index.tsx                 -> /
+//	About.tsx                 -> /about
+//	blog/Index.tsx            -> /blog
+//	blog/[slug].tsx           -> /blog/:slug
+//	docs/[...path].tsx        -> /docs/*path   (至少一段)
+//	shop/[[...path]].tsx      -> /shop/*path   (可以为空)
+//	(marketing)/Pricing.tsx   -> /pricing      (括号目录只用于分组, 不出现在路径中)
+//
+// 以 _ 开头的文件和目录, 以及 NotFound、Error 页面不生成路由
+type PageRoute struct {
+	// gin 路由, 例如 /blog/:slug
+	Path string
+	// 页面组件名, 即相对于 frontend/pages 且不带扩展名的路径, 例如 blog/[slug]
+	Component string
+	// 相对于 frontend/pages 的源文件路径
+	File string
+
+	segments []int
+	// [...path] 形式的通配段, 不匹配空路径
+	requiredCatchAll string
+}
+
+// FSRouter 根据 frontend/pages 目录结构自动注册页面路由
+// 页面列表来自产物清单, 只部署构建产物 (包括嵌入到二进制中) 时同样可用
+//
+//	router := server.NewFSRouter().
+//		Loader("blog/[slug]", func(c *gin.Context) (any, error) {
+//			return dao.GetPost(c, c.Param("slug"))
+//		}).
+//		Handle("Login", loginHandler)
+//	if err := router.Register(r); err != nil {
+//		panic(err)
+//	}
+type FSRouter struct {
+	loaders        map[string]PageLoader
+	handlers       map[string][]gin.HandlerFunc
+	languagePrefix bool
+}
+
+// NewFSRouter 创建文件路由, 默认为每种语言注册带前缀的路由
+func NewFSRouter() *FSRouter {
+	return &FSRouter{
+		loaders:        map[string]PageLoader{},
+		handlers:       map[string][]gin.HandlerFunc{},
+		languagePrefix: true,
+	}
+}
+
+// Loader 为页面设置数据加载函数
+func (r *FSRouter) Loader(component string, loader PageLoader) *FSRouter {
+	r.loaders[component] = loader
+	return r
+}
+
+// Handle 使用自定义 handler 替代页面的默认 handler
+func (r *FSRouter) Handle(component string, handlers ...gin.HandlerFunc) *FSRouter {
+	r.handlers[component] = handlers
+	return r
+}
+
+// LanguagePrefix 设置是否为 i18n.SupportedLanguages 中的每种语言注册 /{lang} 前缀的路由
+func (r *FSRouter) LanguagePrefix(enabled bool) *FSRouter {
+	r.languagePrefix = enabled
+	return r
+}
+
+// Routes 根据产物清单中的页面入口生成路由, 已按匹配优先级排序
+func (r *FSRouter) Routes() ([]PageRoute, error) {
+	return manifestPageRoutes(currentManifest())
+}
+
+// Register 将页面路由注册到 gin, 每个路由同时响应 GET 和 HEAD
+func (r *FSRouter) Register(engine *gin.Engine) error {
+	routes, err := r.Routes()
+	if err != nil {
+		return err
+	}
+
+	for component := range r.loaders {
+		if !slices.ContainsFunc(routes, func(route PageRoute) bool { return route.Component == component }) {
+			return fmt.Errorf("loader for unknown page %q", component)
+		}
+	}
+
+	renderer, _ := engine.HTMLRender.(*TemplateRenderer)
+
+	for _, route := range routes {
+		handlers, ok := r.handlers[route.Component]
+		if !ok {
+			handlers = []gin.HandlerFunc{r.pageHandler(renderer, route)}
+		}
+
+		for _, p := range r.Paths(route) {
+			if err := registerRoute(engine, p, handlers); err != nil {
+				return fmt.Errorf("register %s for %s: %w", p, route.File, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Paths 返回 Register 为路由注册的路径, 包括语言前缀版本
+func (r *FSRouter) Paths(route PageRoute) []string {
+	paths := []string{route.Path}
+	if !r.languagePrefix {
+		return paths
+	}
+
+	for _, lang := range i18n.SupportedLanguages {
+		if route.Path == "/" {
+			paths = append(paths, "/"+lang)
+		} else {
+			paths = append(paths, "/"+lang+route.Path)
+		}
+	}
+	return paths
+}
+
+// registerRoute 注册路由, 将 gin 的路由冲突 panic 转为错误
+func registerRoute(engine *gin.Engine, p string, handlers []gin.HandlerFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+
+	engine.GET(p, handlers...)
+	engine.HEAD(p, handlers...)
+	return nil
+}
+
+// pageHandler 页面的默认 handler, 有 loader 时使用 loader 的结果作为 props, 否则传入路由参数
+func (r *FSRouter) pageHandler(renderer *TemplateRenderer, route PageRoute) gin.HandlerFunc {
+	loader := r.loaders[route.Component]
+
+	return func(c *gin.Context) {
+		if route.requiredCatchAll != "" && strings.Trim(c.Param(route.requiredCatchAll), "/") == "" {
+			renderPageError(renderer, c, http.StatusNotFound, nil)
+			return
+		}
+
+		var props any = gin.H{"params": routeParams(c)}
+		if loader != nil {
+			data, err := loader(c)
+			if errors.Is(err, ErrPageNotFound) {
+				renderPageError(renderer, c, http.StatusNotFound, nil)
+				return
+			}
+			if err != nil {
+				xlog.Error("load page failed", xlog.String("component", route.Component), xlog.String("path", c.Request.URL.Path), xlog.Err(err))
+				renderPageError(renderer, c, http.StatusInternalServerError, err)
+				return
+			}
+			props = data
+		}
+
+		c.HTML(http.StatusOK, route.Component, props)
+	}
+}
+
+// renderPageError 渲染错误页面, 没有使用 TemplateRenderer 时只输出状态码
+func renderPageError(renderer *TemplateRenderer, c *gin.Context, status int, cause error) {
+	if renderer == nil {
+		c.Status(status)
+		return
+	}
+	renderErrorPage(renderer, c, status, cause)
+}
+
+// routeParams 路由参数, 通配段去掉开头的 /
+func routeParams(c *gin.Context) map[string]string {
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = strings.TrimPrefix(param.Value, "/")
+	}
+	return params
+}
+
+// manifestPageRoutes 根据产物清单中的页面入口生成路由, 清单为空时说明还没有构建
+func manifestPageRoutes(manifest *AssetManifest) ([]PageRoute, error) {
+	if len(manifest.Entries) == 0 {
+		return nil, errors.New("asset manifest is empty, build the frontend first")
+	}
+
+	var files []string
+	for _, entry := range manifest.Entries {
+		if entry.Page != "" {
+			files = append(files, entry.Page)
+		}
+	}
+	sort.Strings(files)
+
+	return pageRoutes(files)
+}
+
+// pageRoutes 根据相对于页面目录的文件路径生成路由
+func pageRoutes(files []string) ([]PageRoute, error) {
+	var routes []PageRoute
+	seen := map[string]string{}
+	for _, file := range files {
+		route, ok, err := pageRouteFromFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if other, exists := seen[route.Path]; exists {
+			return nil, fmt.Errorf("pages %s and %s both resolve to %s", other, route.File, route.Path)
+		}
+		seen[route.Path] = route.File
+		routes = append(routes, route)
+	}
+
+	sortPageRoutes(routes)
+	return routes, nil
+}
+
+// pageRouteFromFile 根据相对于页面目录的文件路径生成路由, 不需要路由的文件返回 false
+func pageRouteFromFile(file string) (PageRoute, bool, error) {
+	component := strings.TrimSuffix(file, path.Ext(file))
+	if component == NotFoundComponent || component == ErrorComponent {
+		return PageRoute{}, false, nil
+	}
+
+	route := PageRoute{Component: component, File: file}
+
+	parts := strings.Split(component, "/")
+	var segments []string
+	for i, part := range parts {
+		last := i == len(parts)-1
+
+		switch {
+		case strings.HasPrefix(part, "_"):
+			return PageRoute{}, false, nil
+		case strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")"):
+			// 路由分组
+			if last {
+				return PageRoute{}, false, fmt.Errorf("page %s: route group can only be a directory", file)
+			}
+		case last && strings.EqualFold(part, "index"):
+			// 目录首页
+		case strings.HasPrefix(part, "[[...") && strings.HasSuffix(part, "]]"),
+			strings.HasPrefix(part, "[...") && strings.HasSuffix(part, "]"):
+			if !last {
+				return PageRoute{}, false, fmt.Errorf("page %s: catch-all segment must be the last one", file)
+			}
+			name := strings.Trim(part, "[].")
+			if name == "" {
+				return PageRoute{}, false, fmt.Errorf("page %s: empty catch-all name", file)
+			}
+			if !strings.HasPrefix(part, "[[") {
+				route.requiredCatchAll = name
+			}
+			segments = append(segments, "*"+name)
+			route.segments = append(route.segments, segmentCatchAll)
+		case strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]"):
+			name := strings.Trim(part, "[]")
+			if name == "" {
+				return PageRoute{}, false, fmt.Errorf("page %s: empty dynamic segment name", file)
+			}
+			segments = append(segments, ":"+name)
+			route.segments = append(route.segments, segmentParam)
+		default:
+			segments = append(segments, kebabCase(part))
+			route.segments = append(route.segments, segmentStatic)
+		}
+	}
+
+	route.Path = "/" + strings.Join(segments, "/")
+	return route, true, nil
+}
+
+// sortPageRoutes 静态段优先, 段数多的优先, 保证列表与匹配优先级一致
+func sortPageRoutes(routes []PageRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i].segments, routes[j].segments
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return routes[i].Path < routes[j].Path
+	})
+}
+
+// kebabCase AboutUs -> about-us, 已经是小写或包含 - 的保持不变
+func kebabCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPageRouteFromFile(t *testing.T) {
+	tests := []struct {
+		file      string
+		path      string
+		component string
+		ok        bool
+		err       string
+	}{
+		{file: "Index.tsx", path: "/", component: "Index", ok: true},
+		{file: "About.tsx", path: "/about", component: "About", ok: true},
+		{file: "AboutUs.tsx", path: "/about-us", component: "AboutUs", ok: true},
+		{file: "blog/Index.tsx", path: "/blog", component: "blog/Index", ok: true},
+		{file: "blog/[slug].tsx", path: "/blog/:slug", component: "blog/[slug]", ok: true},
+		{file: "docs/[...path].tsx", path: "/docs/*path", component: "docs/[...path]", ok: true},
+		{file: "shop/[[...path]].jsx", path: "/shop/*path", component: "shop/[[...path]]", ok: true},
+		{file: "(marketing)/Pricing.tsx", path: "/pricing", component: "(marketing)/Pricing", ok: true},
+		{file: "_components/Nav.tsx"},
+		{file: "blog/_Draft.tsx"},
+		{file: "NotFound.tsx"},
+		{file: "Error.tsx"},
+		{file: "(group).tsx", err: "route group can only be a directory"},
+		{file: "docs/[...path]/Edit.tsx", err: "catch-all segment must be the last one"},
+		{file: "blog/[].tsx", err: "empty dynamic segment name"},
+		{file: "docs/[...].tsx", err: "empty catch-all name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			route, ok, err := pageRouteFromFile(tt.file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || route.Path != tt.path || route.Component != tt.component {
+				t.Errorf("got (%q, %q, %v), want (%q, %q, %v)", route.Path, route.Component, ok, tt.path, tt.component, tt.ok)
+			}
+		})
+	}
+}
+
+func TestManifestPageRoutes(t *testing.T) {
+	manifest := &AssetManifest{Entries: map[string]*ManifestEntry{
+		"app":               {File: "app-AAAAAAAA.js"},
+		"app/Index":         {File: "app/Index-AAAAAAAA.js", Page: "Index.tsx"},
+		"app/blog/[slug]":   {File: "app/blog/[slug]-AAAAAAAA.js", Page: "blog/[slug].tsx"},
+		"app/blog/New":      {File: "app/blog/New-AAAAAAAA.js", Page: "blog/New.tsx"},
+		"app/docs/[...all]": {File: "app/docs/[...all]-AAAAAAAA.js", Page: "docs/[...all].tsx"},
+		"app/NotFound":      {File: "app/NotFound-AAAAAAAA.js", Page: "NotFound.tsx"},
+	}}
+
+	routes, err := manifestPageRoutes(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, route := range routes {
+		paths = append(paths, route.Path)
+	}
+	want := "/blog/new /blog/:slug /docs/*all /"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("routes = %q, want %q", got, want)
+	}
+
+	if _, err := manifestPageRoutes(&AssetManifest{}); err == nil {
+		t.Error("expected error for empty manifest")
+	}
+
+	manifest.Entries["app/(marketing)/Index"] = &ManifestEntry{Page: "(marketing)/Index.tsx"}
+	if _, err := manifestPageRoutes(manifest); err == nil || !strings.Contains(err.Error(), "both resolve to /") {
+		t.Errorf("err = %v, want duplicate route error", err)
+	}
+}
@@ -3,8 +3,10 @@ package server
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -84,6 +86,11 @@ func (h *HMRBroadcaster) Broadcast(event string) {
 	h.doBroadcast(event)
 }
 
+// Send 立即发送事件, 不参与节流, 用于类型检查结果这类不会触发刷新的通知
+func (h *HMRBroadcaster) Send(event string) {
+	h.doBroadcast(event)
+}
+
 // 执行实际的广播
 func (h *HMRBroadcaster) doBroadcast(event string) {
 	h.mutex.RLock()
@@ -110,17 +117,83 @@ func (h *HMRBroadcaster) doBroadcast(event string) {
 	}
 }
 
-func setupDev(r *gin.Engine) {
-	BuildJS()
+func setupDev(r *gin.Engine, config *BuildConfig) {
+	// dev 服务器默认以开发模式构建, 不压缩以加快增量构建
+	config = devConfig(config)
+
+	// 开发模式下项目安装了 react-refresh 时, 组件变动只替换模块, 其他情况刷新页面
+	clientScript, err := Templates.ReadFile("templates/dev/hmr-client.js")
+	if err != nil {
+		xlog.Error("read hmr client", xlog.Err(err))
+	}
+	if config.Mode == ModeDevelopment {
+		runtime, err := buildRefreshRuntime(config)
+		if err != nil {
+			xlog.Info("fast refresh disabled, install react-refresh to keep component state on updates", xlog.Err(err))
+		} else {
+			config.fastRefresh = true
+			clientScript = append(runtime, clientScript...)
+		}
+	}
+
 	// 创建 HMR 广播器
 	hmrBroadcaster := NewHMRBroadcaster()
 
-	// 监听 frontend 目录, 有变动就重新构建
+	// 类型检查在后台执行, 不阻塞构建和刷新, 结果通过 HMR 通道显示在浏览器中
+	checker := newTypeChecker(config)
+	checkTypes := func() {
+		if !config.TypeCheck {
+			return
+		}
+		checker.Start(func(result *TypeCheckResult) {
+			hmrBroadcaster.Send(typeCheckEvent(result))
+		})
+	}
+	buildConfig := *config
+	buildConfig.TypeCheck = false
+
+	// 增量构建, 复用 esbuild context
+	builder := NewIncrementalBuilder(config)
+
+	// 所有构建都通过协调器串行执行, 避免多个监听同时构建
+	coordinator := NewBuildCoordinator(func(force bool) error {
+		err := NewJSBuilder(&buildConfig).Build(force)
+		// 完整构建后重新记录快照
+		builder.Reset()
+		if primeErr := builder.Prime(); primeErr != nil {
+			xlog.Error("prime incremental builder", xlog.Err(primeErr))
+		}
+		return err
+	}, builder.Rebuild)
+	// 构建和渲染错误通过 HMR 通道显示在浏览器中, 构建成功后自动关闭
+	devErrors = newDevErrorState(config, hmrBroadcaster.Send)
+	// 构建成功并且有产物变动时才需要刷新页面
+	changed := func(status BuildStatus) bool {
+		devErrors.reportBuild(status)
+		return status.Success && (status.Full || len(status.Changed) > 0)
+	}
+
+	// 构建参数的 hash 包含是否开启 Fast Refresh, 之前的构建产物没有登记模块时会重新构建
+	changed(coordinator.Build(false))
+	checkTypes()
+
+	// 监听 frontend 目录, 有变动就增量构建
 	xutil.Go(context.Background(), func() {
-		frontendDir := filepath.Join(".", "frontend")
+		frontendDir := config.FrontendDir
 		xlog.Debug("HMR init: start watch frontend dir", xlog.String("dir", frontendDir))
 		watchDir(frontendDir, func(event fsnotify.Event) {
-			BuildJS()
+			before := currentManifest()
+			status := coordinator.Rebuild()
+			if !changed(status) {
+				return
+			}
+			checkTypes()
+			// 可以替换模块时不刷新页面, 更新需要按顺序送达, 不参与节流
+			if update, ok := refreshEvent(config, status, before, currentManifest()); ok {
+				xlog.Debug("frontend dir changed, sending module update", xlog.Any("event", event), xlog.Any("changed", status.Changed))
+				hmrBroadcaster.Send(update)
+				return
+			}
 			xlog.Debug("frontend dir changed, broadcasting hmr event", xlog.Any("event", event))
 			hmrBroadcaster.Broadcast("hmr")
 		})
@@ -138,15 +211,74 @@ func setupDev(r *gin.Engine) {
 	})
 
 	xutil.Go(context.Background(), func() {
-		packageJson := filepath.Join(".", "package.json")
+		packageJson := filepath.Join(config.RootDir, "package.json")
 		xlog.Debug("HMR init: start watch package.json", xlog.String("file", packageJson))
 		watchFileContentChange([]string{packageJson}, func(changedFiles []string) {
-			BuildJS()
+			// 依赖变动时完整构建
+			if !changed(coordinator.Build(false)) {
+				return
+			}
 			xlog.Debug("package.json changed, broadcasting hmr event", xlog.Any("changedFiles", changedFiles))
 			hmrBroadcaster.Broadcast("hmr")
+			checkTypes()
+		})
+	})
+
+	// tsconfig.json/jsconfig.json 及其 extends 的配置文件变动时别名可能变化, 需要完整构建
+	xutil.Go(context.Background(), func() {
+		files := config.tsconfigChain()
+		xlog.Debug("HMR init: start watch tsconfig files", xlog.Any("files", files))
+		watchFileContentChange(files, func(changedFiles []string) {
+			if !changed(coordinator.Build(false)) {
+				return
+			}
+			xlog.Debug("tsconfig files changed, broadcasting hmr event", xlog.Any("changedFiles", changedFiles))
+			hmrBroadcaster.Broadcast("hmr")
+			checkTypes()
+		})
+	})
+
+	// .env 文件变动时重新注入环境变量, 需要完整构建
+	xutil.Go(context.Background(), func() {
+		envFiles := config.envFiles()
+		xlog.Debug("HMR init: start watch env files", xlog.Any("files", envFiles))
+		watchFileContentChange(envFiles, func(changedFiles []string) {
+			if !changed(coordinator.Build(true)) {
+				return
+			}
+			xlog.Debug("env files changed, broadcasting hmr event", xlog.Any("changedFiles", changedFiles))
+			hmrBroadcaster.Broadcast("hmr")
 		})
 	})
 
+	// 最近一次构建的结果
+	r.GET("/hmr/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, coordinator.Status())
+	})
+
+	// 页面中引入的 HMR 客户端, 开启 Fast Refresh 时包含 React Refresh runtime
+	r.GET("/hmr/client.js", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", clientScript)
+	})
+
+	// 变动模块的更新, 依赖从页面中已经加载的模块读取
+	// 打包时会读取临时前端目录, 通过协调器避免与构建同时进行
+	r.GET(refreshModulePath+"*id", func(c *gin.Context) {
+		var content []byte
+		err := coordinator.View(func() (err error) {
+			content, err = buildRefreshModule(config, strings.TrimPrefix(c.Param("id"), "/"))
+			return err
+		})
+		if err != nil {
+			xlog.Error("build module update", xlog.String("module", c.Param("id")), xlog.Err(err))
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", content)
+	})
+
 	r.GET("/hmr", func(c *gin.Context) {
 		xlog.Debug("receive hmr connection request")
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
@@ -168,6 +300,14 @@ func setupDev(r *gin.Engine) {
 
 		// 发送连接确认
 		c.SSEvent("connect", "connected")
+		// 刷新后的页面显示还未修复的类型错误
+		if last := checker.Last(); last != nil && len(last.Diagnostics) > 0 {
+			c.SSEvent("hmr", typeCheckEvent(last))
+		}
+		// 以及还未修复的构建和渲染错误
+		if event := devErrors.Event(); event != "" {
+			c.SSEvent("hmr", event)
+		}
 		c.Writer.Flush()
 		xlog.Debug("hmr connection established, waiting for events...", xlog.String("clientID", clientID))
 
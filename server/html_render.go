@@ -1,15 +1,16 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
 
-	"github.com/daodao97/goreact/base/login"
 	"github.com/daodao97/goreact/conf"
 	"github.com/daodao97/goreact/i18n"
 	"github.com/daodao97/xgo/xapp"
+	"github.com/daodao97/xgo/xlog"
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,49 +30,141 @@ func (r *HTMLRender) Render(w http.ResponseWriter) error {
 	r.WriteContentType(w)
 
 	var htmlContent template.HTML
-	var err error
 
 	if r.ComponentName != "" {
-		htmlContent, err = r.renderer.RenderReact(r.ginContext, r.ComponentName, r.Data)
+		writePreloadHints(w, r.ComponentName)
+	}
+
+	pageState := NewPageState(r.ginContext, r.Data)
+	state, err := encodePageState(r.ginContext, pageState)
+	if err != nil {
+		return r.renderError(w, err)
+	}
+
+	if r.ComponentName != "" {
+		htmlContent, err = r.renderer.renderReact(r.ginContext, r.ComponentName, r.Data, state)
 		if err != nil {
+			return r.renderError(w, err)
+		}
+		devErrors.renderOK(r.ComponentName)
 
-			return r.Template.ExecuteTemplate(w, "error.html", map[string]any{
-				"Title":         "服务端渲染失败",
-				"ErrorMessage":  fmt.Sprintf("错误：渲染 React 时出错 %+v\n", err),
-				"ComponentName": r.ComponentName,
-				"RequestInfo":   r.ginContext.Request.URL.Path,
-				"IsDev":         xapp.IsDev(),
-			})
+		// 应用组件设置的状态码、响应头和 cookie, 重定向时不再输出 body
+		resp := GetSSRResponse(r.ginContext)
+		resp.Apply(w)
+		if resp.IsRedirect() {
+			return nil
 		}
 	}
 
-	data := extendPayload(r.Data, r.TemplateName, r.ComponentName, htmlContent)
+	return r.renderPage(w, 0, r.ComponentName, pageState, state, htmlContent)
+}
 
-	data.Translations = i18n.GetTranslations(r.ginContext)
-	data.Lang = r.ginContext.GetString("lang")
+// renderPage 将组件渲染结果和公共数据填充到 go template, status 为 0 时使用已经设置的状态码
+func (r *HTMLRender) renderPage(w http.ResponseWriter, status int, componentName string, pageState *PageState, state []byte, htmlContent template.HTML) error {
+	data := extendPayload(pageState.Props, r.TemplateName, componentName, htmlContent)
+
+	data.State = pageStateHTML(state)
+	data.Translations = pageState.Translations
 	data.Website = &conf.Get().Website
-	userInfo, err := login.GetUserInfo(r.ginContext)
-	if err == nil {
-		data.UserInfo = userInfo
+	data.UserInfo = pageState.UserInfo
+	if htmlContent != "" {
+		data.CriticalCSS = criticalCSS(componentName)
 	}
+	data.Lang = r.ginContext.GetString("lang")
 
 	data.GoogleAdsTxt = conf.Get().GoogleAdsTxt
 	data.GoogleAdsJS = conf.Get().GoogleAdsJS
 	data.GoogleAnalytics = conf.Get().GoogleAnalytics
 	data.MicrosoftClarityId = conf.Get().MicrosoftClarityId
-	data.Head = i18n.GetHead(r.ginContext, strings.ToLower(strings.TrimSuffix(r.ComponentName, ".js")))
+	data.Head = i18n.GetHead(r.ginContext, strings.ToLower(strings.TrimSuffix(componentName, ".js")))
 
 	data.Version = conf.Get().GitTag
 	if xapp.IsDev() {
 		data.Version = "dev"
 	}
 
-	// 先执行模板渲染，然后再释放资源
-	err = r.Template.ExecuteTemplate(w, r.TemplateName, data)
+	// 先渲染到缓冲区, 计算 ETag 后再输出
+	var body bytes.Buffer
+	if err := r.Template.ExecuteTemplate(&body, r.TemplateName, data); err != nil {
+		return err
+	}
+
+	return r.writeBody(w, status, body.Bytes())
+}
+
+// writeBody 输出缓存策略和 ETag, If-None-Match 命中时返回 304
+// 响应头需要在状态码之前设置, status 不为 0 时在设置响应头之后输出
+func (r *HTMLRender) writeBody(w http.ResponseWriter, status int, body []byte) error {
+	policy := GetCachePolicy(r.ginContext)
+	applyCachePolicy(w, policy)
+
+	if (policy == nil || !policy.DisableETag) && r.isConditional(w, status) {
+		etag := strongETag(body)
+		w.Header().Set("ETag", etag)
+
+		if etagMatches(r.ginContext.GetHeader("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
 
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	_, err := w.Write(body)
 	return err
 }
 
+// isConditional 只有成功的 GET/HEAD 请求支持条件请求
+func (r *HTMLRender) isConditional(w http.ResponseWriter, status int) bool {
+	method := r.ginContext.Request.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+
+	if status != 0 {
+		return status == http.StatusOK
+	}
+	if sw, ok := w.(interface{ Status() int }); ok {
+		return sw.Status() == http.StatusOK
+	}
+	return true
+}
+
+// renderError 服务端渲染失败时返回 500, 优先渲染约定的 Error 页面组件
+func (r *HTMLRender) renderError(w http.ResponseWriter, renderErr error) error {
+	xlog.Error("render react failed",
+		xlog.String("path", r.ginContext.Request.URL.Path),
+		xlog.String("component", r.ComponentName),
+		xlog.Err(renderErr))
+
+	// dev 模式下在浏览器中显示错误位置
+	devErrors.reportRender(r.ComponentName, renderErr)
+
+	errorComponent := ErrorComponent + ".js"
+	if r.ComponentName != errorComponent && hasPageComponent(ErrorComponent) {
+		data := errorPageData(r.ginContext, http.StatusInternalServerError, renderErr)
+		pageState := NewPageState(r.ginContext, data)
+		state, err := encodePageState(r.ginContext, pageState)
+		if err == nil {
+			htmlContent, err := r.renderer.renderReact(r.ginContext, errorComponent, data, state)
+			if err == nil {
+				return r.renderPage(w, http.StatusInternalServerError, errorComponent, pageState, state, htmlContent)
+			}
+		}
+		xlog.Error("render error page failed", xlog.Err(err))
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	return r.Template.ExecuteTemplate(w, "error.html", map[string]any{
+		"Title":         i18n.GetWithDefault(r.ginContext, "error.server.title", "服务端渲染失败"),
+		"ErrorMessage":  fmt.Sprintf("错误：渲染 React 时出错 %+v\n", renderErr),
+		"ComponentName": r.ComponentName,
+		"RequestInfo":   r.ginContext.Request.URL.Path,
+		"IsDev":         xapp.IsDev(),
+	})
+}
+
 // WriteContentType 设置内容类型
 func (r *HTMLRender) WriteContentType(w http.ResponseWriter) {
 	header := w.Header()
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTMLRenderWriteBody(t *testing.T) {
+	body := []byte("<html></html>")
+	etag := strongETag(body)
+
+	tests := []struct {
+		name        string
+		status      int
+		ifNoneMatch string
+		wantCode    int
+		wantETag    bool
+	}{
+		{"ok", 0, "", http.StatusOK, true},
+		{"not modified", 0, etag, http.StatusNotModified, true},
+		{"error status", http.StatusInternalServerError, "", http.StatusInternalServerError, false},
+		{"error status ignores if-none-match", http.StatusInternalServerError, etag, http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ResponseRecorder 在 WriteHeader 时固定响应头, 之后设置的响应头不会输出
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			c.Set(cachePolicyKey, &CachePolicy{Public: true, MaxAge: time.Minute, Vary: []string{"Cookie"}})
+
+			r := &HTMLRender{ginContext: c}
+			if err := r.writeBody(w, tt.status, body); err != nil {
+				t.Fatal(err)
+			}
+
+			result := w.Result()
+			if result.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", result.StatusCode, tt.wantCode)
+			}
+			if got := result.Header.Get("Cache-Control"); got != "public, max-age=60" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+			if got := result.Header.Get("Vary"); got != "Cookie" {
+				t.Errorf("Vary = %q", got)
+			}
+			if got := result.Header.Get("ETag"); (got == etag) != tt.wantETag {
+				t.Errorf("ETag = %q, want set %v", got, tt.wantETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 构建产物清单文件名, 位于 BuildDir 下
+	ManifestFileName = "manifest.json"
+
+	// 客户端公共入口 (frontend/app.js) 在清单中的名称
+	appEntryName = "app"
+
+	// 静态资源访问前缀
+	assetsURLPrefix = "/assets/"
+)
+
+// esbuild 输出的文件名中带有 8 位内容 hash, 例如 Home-5XJ2KQ3M.js
+var hashedAssetPattern = regexp.MustCompile(`-[A-Z0-9]{8}\.[a-z0-9]+$`)
+
+// AssetManifest 客户端构建产物清单, 记录入口到带 hash 文件名的映射
+type AssetManifest struct {
+	// 所有产物文件名计算出的 hash, 任意产物变化都会改变
+	Hash    string                    `json:"hash"`
+	Entries map[string]*ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry 单个入口的构建产物, 路径相对于 BuildDir
+type ManifestEntry struct {
+	File string   `json:"file"`
+	CSS  []string `json:"css,omitempty"`
+	// 入口静态导入 (直接或间接) 的所有 chunk, 按依赖图的遍历顺序排列
+	Chunks []string `json:"chunks,omitempty"`
+	// 页面入口对应的源文件, 相对于 frontend/pages, 例如 blog/[slug].tsx
+	Page string `json:"page,omitempty"`
+}
+
+// esbuildMetafile esbuild metafile 中用到的部分
+type esbuildMetafile struct {
+	Inputs  map[string]esbuildMetaInput  `json:"inputs"`
+	Outputs map[string]esbuildMetaOutput `json:"outputs"`
+}
+
+type esbuildMetaInput struct {
+	Bytes   int                 `json:"bytes"`
+	Imports []esbuildMetaImport `json:"imports"`
+}
+
+type esbuildMetaOutput struct {
+	Bytes      int                               `json:"bytes"`
+	EntryPoint string                            `json:"entryPoint"`
+	CSSBundle  string                            `json:"cssBundle"`
+	Imports    []esbuildMetaImport               `json:"imports"`
+	Inputs     map[string]esbuildMetaOutputInput `json:"inputs"`
+}
+
+type esbuildMetaImport struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external"`
+}
+
+type esbuildMetaOutputInput struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+// parseMetafile 解析 esbuild metafile
+func parseMetafile(metafile string) (*esbuildMetafile, error) {
+	meta := &esbuildMetafile{}
+	if err := json.Unmarshal([]byte(metafile), meta); err != nil {
+		return nil, fmt.Errorf("解析 metafile 失败: %w", err)
+	}
+	return meta, nil
+}
+
+// newAssetManifest 根据 metafile 生成产物清单
+// workDir 为 esbuild 的 AbsWorkingDir, metafile 中的路径都相对于它
+// entryRoot 为入口文件根目录, 入口名称为相对于它去掉扩展名的路径, 例如 app/Home
+func newAssetManifest(meta *esbuildMetafile, workDir, entryRoot, outDir string) (*AssetManifest, error) {
+	manifest := &AssetManifest{Entries: map[string]*ManifestEntry{}}
+
+	outputRel := func(p string) (string, error) {
+		rel, err := filepath.Rel(outDir, filepath.Join(workDir, p))
+		if err != nil {
+			return "", err
+		}
+		return filepath.ToSlash(rel), nil
+	}
+
+	var files []string
+	for outPath, output := range meta.Outputs {
+		file, err := outputRel(outPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+
+		if output.EntryPoint == "" || !strings.HasSuffix(outPath, ".js") {
+			continue
+		}
+
+		entryRel, err := filepath.Rel(entryRoot, filepath.Join(workDir, output.EntryPoint))
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.ToSlash(strings.TrimSuffix(entryRel, filepath.Ext(entryRel)))
+
+		entry := &ManifestEntry{File: file}
+		if page, ok := strings.CutPrefix(filepath.ToSlash(entryRel), appEntryName+"/"); ok {
+			entry.Page = page
+		}
+		if output.CSSBundle != "" {
+			css, err := outputRel(output.CSSBundle)
+			if err != nil {
+				return nil, err
+			}
+			entry.CSS = append(entry.CSS, css)
+		}
+
+		for _, chunkPath := range staticImportGraph(meta, outPath) {
+			chunk, err := outputRel(chunkPath)
+			if err != nil {
+				return nil, err
+			}
+			entry.Chunks = append(entry.Chunks, chunk)
+		}
+
+		manifest.Entries[name] = entry
+	}
+
+	sort.Strings(files)
+	hash := sha256.Sum256([]byte(strings.Join(files, "\n")))
+	manifest.Hash = fmt.Sprintf("%x", hash[:8])
+
+	return manifest, nil
+}
+
+// staticImportGraph 遍历产物的静态导入图, 返回入口需要的所有 chunk (不含入口自身)
+// 动态 import() 的 chunk 按需加载, 不计入
+func staticImportGraph(meta *esbuildMetafile, outPath string) []string {
+	var chunks []string
+	visited := map[string]bool{outPath: true}
+
+	var walk func(string)
+	walk = func(p string) {
+		for _, imp := range meta.Outputs[p].Imports {
+			if imp.External || imp.Kind != "import-statement" || visited[imp.Path] {
+				continue
+			}
+			visited[imp.Path] = true
+			chunks = append(chunks, imp.Path)
+			walk(imp.Path)
+		}
+	}
+	walk(outPath)
+
+	return chunks
+}
+
+// writeManifest 将产物清单写入 BuildDir
+func writeManifest(outDir string, manifest *AssetManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, ManifestFileName), content, DefaultFileMode)
+}
+
+var (
+	manifestMu     sync.RWMutex
+	loadedManifest *AssetManifest
+)
+
+// currentManifest 获取当前产物清单, 首次使用时从构建产物中加载
+func currentManifest() *AssetManifest {
+	manifestMu.RLock()
+	manifest := loadedManifest
+	manifestMu.RUnlock()
+
+	if manifest != nil {
+		return manifest
+	}
+
+	return reloadManifest()
+}
+
+// reloadManifest 重新加载产物清单, 构建完成后调用
+func reloadManifest() *AssetManifest {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest := &AssetManifest{Entries: map[string]*ManifestEntry{}}
+
+	content, err := fs.ReadFile(BuildFS(), ManifestFileName)
+	if err != nil {
+		xlog.Debug("load asset manifest failed", xlog.Err(err))
+	} else if err := json.Unmarshal(content, manifest); err != nil {
+		xlog.Warn("parse asset manifest failed", xlog.Err(err))
+	}
+
+	loadedManifest = manifest
+	resetCriticalCSS()
+	return manifest
+}
+
+// entryName 将组件名转换为清单中的入口名称
+// Home.js -> app/Home, app -> app
+func entryName(name string) string {
+	name = strings.TrimSuffix(name, ".js")
+	if name == appEntryName || strings.HasPrefix(name, appEntryName+"/") {
+		return name
+	}
+	return appEntryName + "/" + name
+}
+
+// assetURL 模板函数, 返回入口 JS 的访问地址
+// 清单不存在时回退到不带 hash 的文件名
+func assetURL(name string) string {
+	if entry, ok := currentManifest().Entries[entryName(name)]; ok {
+		return assetsURLPrefix + entry.File
+	}
+	return assetsURLPrefix + entryName(name) + ".js"
+}
+
+// assetCSS 模板函数, 返回入口关联的 CSS 访问地址
+func assetCSS(name string) []string {
+	entry, ok := currentManifest().Entries[entryName(name)]
+	if !ok {
+		if entryName(name) == appEntryName {
+			return []string{assetsURLPrefix + appEntryName + ".css"}
+		}
+		return nil
+	}
+
+	urls := make([]string, 0, len(entry.CSS))
+	for _, css := range entry.CSS {
+		urls = append(urls, assetsURLPrefix+css)
+	}
+	return urls
+}
+
+// AssetCacheMiddleware 为带内容 hash 的静态资源设置长期缓存
+func AssetCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hashedAssetPattern.MatchString(c.Request.URL.Path) {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			c.Header("Cache-Control", "public, max-age=0, must-revalidate")
+		}
+		c.Next()
+	}
+}
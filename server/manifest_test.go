@@ -0,0 +1,42 @@
+package server
+
+import "testing"
+
+func TestNewAssetManifest(t *testing.T) {
+	meta := &esbuildMetafile{Outputs: map[string]esbuildMetaOutput{
+		"build/app-AAAAAAAA.js": {EntryPoint: ".goreact/app.js", CSSBundle: "build/app-BBBBBBBB.css"},
+		"build/app/blog/[slug]-CCCCCCCC.js": {
+			EntryPoint: ".goreact/app/blog/[slug].tsx",
+			Imports:    []esbuildMetaImport{{Path: "build/chunk-DDDDDDDD.js", Kind: "import-statement"}},
+		},
+		"build/chunk-DDDDDDDD.js": {
+			Imports: []esbuildMetaImport{
+				{Path: "build/chunk-EEEEEEEE.js", Kind: "import-statement"},
+				{Path: "build/chunk-FFFFFFFF.js", Kind: "dynamic-import"},
+			},
+		},
+		"build/chunk-EEEEEEEE.js": {},
+		"build/chunk-FFFFFFFF.js": {},
+	}}
+
+	manifest, err := newAssetManifest(meta, "/project", "/project/.goreact", "/project/build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := manifest.Entries[appEntryName]
+	if app == nil || app.File != "app-AAAAAAAA.js" || len(app.CSS) != 1 || app.CSS[0] != "app-BBBBBBBB.css" || app.Page != "" {
+		t.Errorf("app entry = %+v", app)
+	}
+
+	page := manifest.Entries["app/blog/[slug]"]
+	if page == nil {
+		t.Fatalf("missing page entry, got %v", manifest.Entries)
+	}
+	if page.Page != "blog/[slug].tsx" {
+		t.Errorf("Page = %q, want blog/[slug].tsx", page.Page)
+	}
+	if len(page.Chunks) != 2 || page.Chunks[0] != "chunk-DDDDDDDD.js" || page.Chunks[1] != "chunk-EEEEEEEE.js" {
+		t.Errorf("Chunks = %v, want static imports only", page.Chunks)
+	}
+}
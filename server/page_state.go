@@ -0,0 +1,400 @@
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strings"
+
+	"github.com/daodao97/goreact/base/login"
+	"github.com/daodao97/goreact/conf"
+	"github.com/daodao97/goreact/i18n"
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 页面状态 script 标签的 id
+	pageStateElementID = "__GOREACT_STATE__"
+
+	// 结构体字段带有该 tag 时不会序列化到页面状态中
+	//
+	//	type Product struct {
+	//		Name string `json:"name"`
+	//		Cost int    `json:"cost" goreact:"server"`
+	//	}
+	stateTagName   = "goreact"
+	stateTagServer = "server"
+
+	// 服务端渲染时 ssrRequestScript 已经设置了包含 cookie 的请求信息, 页面状态中的请求信息只在客户端使用
+	stateApplyScript = `(function (s) {
+  window.INITIAL_PROPS = s.props;
+  window.TRANSLATIONS = s.translations;
+  window.WEBSITE = s.website;
+  window.USER_INFO = s.userInfo;
+  window.LANG = s.lang;
+  window.__GOREACT_REQUEST__ = window.__GOREACT_REQUEST__ || s.request;
+  window.goreact = window.goreact || {};
+  window.goreact.request = window.__GOREACT_REQUEST__;
+})`
+)
+
+// 页面状态大小预算（字节），超出时输出警告
+var stateSizeBudget = 128 * 1024
+
+// SetStateSizeBudget 设置页面状态大小预算, 小于等于 0 时不检查
+func SetStateSizeBudget(size int) {
+	stateSizeBudget = size
+}
+
+// PageState 页面初始状态, 服务端渲染和客户端 hydrate 使用同一份序列化结果
+type PageState struct {
+	Props        any         `json:"props"`
+	Translations any         `json:"translations"`
+	Website      any         `json:"website"`
+	UserInfo     any         `json:"userInfo"`
+	Lang         string      `json:"lang"`
+	Request      *SSRRequest `json:"request"`
+}
+
+// NewPageState 收集当前请求的页面状态
+func NewPageState(c *gin.Context, props any) *PageState {
+	state := &PageState{
+		Props:        props,
+		Translations: i18n.GetTranslations(c),
+		Website:      &conf.Get().Website,
+		Lang:         c.GetString("lang"),
+		Request:      GetSSRRequest(c),
+	}
+
+	if userInfo, err := login.GetUserInfo(c); err == nil {
+		state.UserInfo = userInfo
+	}
+
+	return state
+}
+
+// EncodePageState 序列化当前请求的页面状态
+func EncodePageState(c *gin.Context, props any) ([]byte, error) {
+	return encodePageState(c, NewPageState(c, props))
+}
+
+// encodePageState 序列化页面状态, 超出预算时输出警告
+func encodePageState(c *gin.Context, pageState *PageState) ([]byte, error) {
+	state, err := SerializeState(pageState)
+	if err != nil {
+		return nil, err
+	}
+
+	if stateSizeBudget > 0 && len(state) > stateSizeBudget {
+		xlog.Warn("page state exceeds size budget",
+			xlog.String("path", c.Request.URL.Path),
+			xlog.Int("size", len(state)),
+			xlog.Int("budget", stateSizeBudget))
+	}
+
+	return state, nil
+}
+
+// SerializeState 将任意值序列化为可以安全嵌入 html 的 JSON
+// 会去掉带有 goreact:"server" tag 的字段, <、>、& 以及 U+2028/U+2029 会被转义
+func SerializeState(v any) ([]byte, error) {
+	normalized, err := normalizeState(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(true)
+	if err := encoder.Encode(normalized); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// pageStateHTML 生成页面中嵌入状态的 script 标签
+func pageStateHTML(state []byte) template.HTML {
+	return template.HTML(fmt.Sprintf(
+		`<script type="application/json" id="%s">%s</script>
+<script>%s(JSON.parse(document.getElementById("%s").textContent));</script>`,
+		pageStateElementID, state, stateApplyScript, pageStateElementID))
+}
+
+// pageStateScript 生成 SSR 运行时中设置状态的脚本
+func pageStateScript(state []byte) string {
+	return fmt.Sprintf("%s\n%s(%s);", ssrWindowScript, stateApplyScript, state)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// stateRef 正在序列化的指针、map 或 slice, 用于检测循环引用
+type stateRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// stateNormalizer 记录当前路径上的引用, 同一个值在不同位置出现多次是允许的, 只有出现在自身内部时才是循环
+type stateNormalizer struct {
+	path map[stateRef]bool
+}
+
+// normalizeState 将值转换为 JSON 友好的结构, 并去掉仅服务端使用的字段
+// 实现了 json.Marshaler 的类型 (time.Time、decimal.Decimal 等) 保持自身的序列化方式
+func normalizeState(v reflect.Value) (any, error) {
+	n := &stateNormalizer{path: map[stateRef]bool{}}
+	return n.normalize(v)
+}
+
+func (n *stateNormalizer) normalize(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+			return marshalerValue(v)
+		}
+		if v.Kind() == reflect.Pointer {
+			leave, err := n.enter(v, 0)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return n.normalize(v.Elem())
+	}
+
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return marshalerValue(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return n.normalizeStruct(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		leave, err := n.enter(v, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := stateMapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			val, err := n.normalize(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			out[key] = val
+		}
+		return out, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		// []byte 与 encoding/json 一致, 使用 base64
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface(), nil
+		}
+		if v.Kind() == reflect.Slice {
+			leave, err := n.enter(v, v.Len())
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		out := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			val, err := n.normalize(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = val
+		}
+		return out, nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// enter 记录进入的引用, 已经在当前路径上时说明有循环引用
+func (n *stateNormalizer) enter(v reflect.Value, length int) (func(), error) {
+	ref := stateRef{ptr: v.Pointer(), typ: v.Type(), len: length}
+	if n.path[ref] {
+		return nil, fmt.Errorf("page state contains a cycle via %s", v.Type())
+	}
+	n.path[ref] = true
+	return func() { delete(n.path, ref) }, nil
+}
+
+func marshalerValue(v reflect.Value) (any, error) {
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(raw), nil
+}
+
+func (n *stateNormalizer) normalizeStruct(v reflect.Value) (map[string]any, error) {
+	out := map[string]any{}
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		if field.Tag.Get(stateTagName) == stateTagServer {
+			continue
+		}
+
+		tag, skip := parseJSONTag(field)
+		if skip {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// 匿名结构体字段与 encoding/json 一致, 展开到外层
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			inner := fv
+			if inner.Kind() == reflect.Pointer {
+				if inner.IsNil() {
+					continue
+				}
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct && !inner.Type().Implements(jsonMarshalerType) {
+				embedded, err := n.normalizeStruct(inner)
+				if err != nil {
+					return nil, err
+				}
+				for k, val := range embedded {
+					if _, exists := out[k]; !exists {
+						out[k] = val
+					}
+				}
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+		}
+
+		if tag.omitEmpty && isEmptyStateValue(fv) || tag.omitZero && isZeroStateValue(fv) {
+			continue
+		}
+
+		val, err := n.normalize(fv)
+		if err != nil {
+			return nil, err
+		}
+		out[tag.name] = val
+	}
+
+	return out, nil
+}
+
+// isEmptyStateValue 与 encoding/json 的 omitempty 规则一致
+func isEmptyStateValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// 实现了 IsZero 的类型 (例如 time.Time) 在 omitzero 时使用自己的判断
+var isZeroerType = reflect.TypeOf((*interface{ IsZero() bool })(nil)).Elem()
+
+// isZeroStateValue 与 encoding/json 的 omitzero 规则一致, 优先使用类型的 IsZero 方法, 否则为零值时省略
+func isZeroStateValue(v reflect.Value) bool {
+	if v.Type().Implements(isZeroerType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return true
+		}
+		return v.Interface().(interface{ IsZero() bool }).IsZero()
+	}
+	if reflect.PointerTo(v.Type()).Implements(isZeroerType) {
+		if !v.CanAddr() {
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		return v.Addr().Interface().(interface{ IsZero() bool }).IsZero()
+	}
+	return v.IsZero()
+}
+
+// stateJSONTag 字段的 json tag
+type stateJSONTag struct {
+	name      string
+	omitEmpty bool
+	omitZero  bool
+}
+
+func parseJSONTag(field reflect.StructField) (tag stateJSONTag, skip bool) {
+	raw := field.Tag.Get("json")
+	if raw == "-" {
+		return stateJSONTag{}, true
+	}
+
+	parts := strings.Split(raw, ",")
+	tag.name = parts[0]
+	if tag.name == "" {
+		tag.name = field.Name
+	}
+
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			tag.omitEmpty = true
+		case "omitzero":
+			tag.omitZero = true
+		}
+	}
+
+	return tag, false
+}
+
+func stateMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", k.Interface()), nil
+	}
+	return "", fmt.Errorf("unsupported map key type: %s", k.Type())
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stateZeroer struct {
+	Value int `json:"value"`
+}
+
+func (z stateZeroer) IsZero() bool {
+	return z.Value < 0
+}
+
+type statePointerZeroer struct {
+	Value int `json:"value"`
+}
+
+func (z *statePointerZeroer) IsZero() bool {
+	return z.Value == 1
+}
+
+type stateInner struct {
+	Name string `json:"name"`
+}
+
+type stateOmit struct {
+	EmptyTime      time.Time          `json:"emptyTime,omitempty"`
+	ZeroTime       time.Time          `json:"zeroTime,omitzero"`
+	ZeroStruct     stateInner         `json:"zeroStruct,omitzero"`
+	SetStruct      stateInner         `json:"setStruct,omitzero"`
+	EmptySlice     []int              `json:"emptySlice,omitzero"`
+	NilSlice       []int              `json:"nilSlice,omitzero"`
+	EmptySliceOmit []int              `json:"emptySliceOmit,omitempty"`
+	Zeroer         stateZeroer        `json:"zeroer,omitzero"`
+	NotZeroer      stateZeroer        `json:"notZeroer,omitzero"`
+	PointerZeroer  statePointerZeroer `json:"pointerZeroer,omitzero"`
+	NilPointer     *stateInner        `json:"nilPointer,omitzero"`
+	ZeroInt        int                `json:"zeroInt,omitzero"`
+	Both           string             `json:"both,omitempty,omitzero"`
+	Secret         string             `json:"secret" goreact:"server"`
+}
+
+type stateNode struct {
+	Name string     `json:"name"`
+	Next *stateNode `json:"next,omitempty"`
+}
+
+func TestSerializeStateMatchesEncodingJSON(t *testing.T) {
+	shared := &stateInner{Name: "shared"}
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"omit options", stateOmit{
+			SetStruct:     stateInner{Name: "set"},
+			EmptySlice:    []int{},
+			Zeroer:        stateZeroer{Value: -1},
+			NotZeroer:     stateZeroer{Value: 0},
+			PointerZeroer: statePointerZeroer{Value: 1},
+		}},
+		{"omit options by pointer", &stateOmit{EmptySliceOmit: []int{}, PointerZeroer: statePointerZeroer{Value: 2}}},
+		{"shared pointer", map[string]any{"a": shared, "b": []*stateInner{shared, shared}}},
+		{"linked list", &stateNode{Name: "a", Next: &stateNode{Name: "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SerializeState(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// goreact:"server" 字段不会出现在页面状态中
+			var expected map[string]any
+			if json.Unmarshal(want, &expected) == nil {
+				delete(expected, "secret")
+				want, _ = json.Marshal(expected)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(want, &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(gotValue)
+			wantJSON, _ := json.Marshal(wantValue)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("SerializeState() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestSerializeStateCycle(t *testing.T) {
+	node := &stateNode{Name: "a"}
+	node.Next = &stateNode{Name: "b", Next: node}
+
+	loop := map[string]any{}
+	loop["self"] = loop
+
+	list := []any{nil}
+	list[0] = list
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"pointer", node},
+		{"map", loop},
+		{"slice", list},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SerializeState(tt.value)
+			if err == nil || !strings.Contains(err.Error(), "cycle") {
+				t.Fatalf("SerializeState() error = %v, want cycle error", err)
+			}
+		})
+	}
+}
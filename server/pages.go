@@ -0,0 +1,48 @@
+package server
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// PageInfo 页面组件信息
+type PageInfo struct {
+	// 渲染时使用的组件名, 即 c.HTML(200, Component, data) 中的名称
+	Component string
+	// 相对于 frontend/pages 的源文件路径
+	File string
+	// FSRouter 生成的路由, 不生成路由的页面为空
+	Route string
+	// 客户端入口地址
+	Asset string
+}
+
+// Pages 列出产物清单中的页面组件
+func Pages() ([]PageInfo, error) {
+	manifest := currentManifest()
+
+	var pages []PageInfo
+	for _, entry := range manifest.Entries {
+		if entry.Page == "" {
+			continue
+		}
+
+		component := strings.TrimSuffix(entry.Page, path.Ext(entry.Page))
+		page := PageInfo{Component: component, File: entry.Page, Asset: assetsURLPrefix + entry.File}
+		route, ok, err := pageRouteFromFile(entry.Page)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			page.Route = route.Path
+		}
+		pages = append(pages, page)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].File < pages[j].File
+	})
+
+	return pages, nil
+}
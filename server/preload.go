@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/daodao97/xgo/xlog"
+)
+
+var (
+	// 是否通过 Link 响应头声明 modulepreload
+	preloadLinkHeader = false
+	// 是否在服务端渲染前发送 103 Early Hints
+	preloadEarlyHints = false
+)
+
+// SetPreloadLinkHeader 设置是否在响应头中输出 Link: <...>; rel=modulepreload
+func SetPreloadLinkHeader(enabled bool) {
+	preloadLinkHeader = enabled
+}
+
+// SetEarlyHints 设置是否在服务端渲染前发送 103 Early Hints, 开启后同时输出 Link 响应头
+func SetEarlyHints(enabled bool) {
+	preloadEarlyHints = enabled
+}
+
+// modulePreloads 模板函数, 返回页面需要预加载的模块地址
+// 包含公共入口和组件入口本身, 以及它们静态导入的所有 chunk, 已去重
+func modulePreloads(component string) []string {
+	manifest := currentManifest()
+
+	var urls []string
+	seen := map[string]bool{}
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			urls = append(urls, assetsURLPrefix+file)
+		}
+	}
+
+	for _, name := range []string{entryName(component), appEntryName} {
+		entry, ok := manifest.Entries[name]
+		if !ok {
+			continue
+		}
+		add(entry.File)
+		for _, chunk := range entry.Chunks {
+			add(chunk)
+		}
+	}
+
+	return urls
+}
+
+// writePreloadHints 按配置输出 Link 响应头和 103 Early Hints
+func writePreloadHints(w http.ResponseWriter, component string) {
+	if !preloadLinkHeader && !preloadEarlyHints {
+		return
+	}
+
+	urls := modulePreloads(component)
+	if len(urls) == 0 {
+		return
+	}
+
+	links := make([]string, 0, len(urls))
+	for _, url := range urls {
+		links = append(links, fmt.Sprintf("<%s>; rel=modulepreload", url))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+
+	if !preloadEarlyHints {
+		return
+	}
+
+	// gin 的 ResponseWriter 会把 WriteHeader 当作最终状态码, 需要直接写到底层连接
+	unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+	if !ok {
+		xlog.Debug("response writer does not support early hints")
+		return
+	}
+	unwrapper.Unwrap().WriteHeader(http.StatusEarlyHints)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWritePreloadHintsThroughCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	manifestMu.Lock()
+	previous := loadedManifest
+	loadedManifest = &AssetManifest{Entries: map[string]*ManifestEntry{
+		appEntryName:           {File: "app.js", Chunks: []string{"chunk-react.js"}},
+		appEntryName + "/Home": {File: "Home.js", Chunks: []string{"chunk-react.js"}},
+	}}
+	manifestMu.Unlock()
+	t.Cleanup(func() {
+		manifestMu.Lock()
+		loadedManifest = previous
+		manifestMu.Unlock()
+	})
+
+	SetEarlyHints(true)
+	t.Cleanup(func() { SetEarlyHints(false) })
+
+	r := gin.New()
+	r.Use(CompressMiddleware(DefaultCompressMinSize))
+	r.GET("/", func(c *gin.Context) {
+		writePreloadHints(c.Writer, "Home")
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.String(http.StatusOK, strings.Repeat("<p>home</p>", 200))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Get("Link"))
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != encodingGzip {
+		t.Fatalf("response = %d %q, want a gzip encoded 200", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	want := "</assets/Home.js>; rel=modulepreload, </assets/chunk-react.js>; rel=modulepreload, </assets/app.js>; rel=modulepreload"
+	if len(hints) != 1 || hints[0] != want {
+		t.Errorf("early hints = %q, want [%q]", hints, want)
+	}
+	if link := resp.Header.Get("Link"); link != want {
+		t.Errorf("Link = %q, want %q", link, want)
+	}
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/daodao97/goreact/conf"
 	"github.com/daodao97/xgo/xapp"
@@ -9,19 +11,70 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Gin() *gin.Engine {
+// GinOptions Gin 的选项
+type GinOptions struct {
+	// 构建配置, 为空时使用 SetBuildConfig 设置的配置 (默认以当前目录为项目根目录)
+	Config *BuildConfig
+}
+
+// WithConfig 使用指定的构建配置, 同时设置为当前使用的构建配置
+func WithConfig(config *BuildConfig) func(*GinOptions) {
+	return func(options *GinOptions) {
+		options.Config = config
+	}
+}
+
+func Gin(opts ...func(*GinOptions)) *gin.Engine {
+	options := &GinOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// 没有指定配置时使用 goreact dev/start 传入的配置文件
+	if options.Config == nil && os.Getenv(ConfigEnv) != "" {
+		config, err := LoadBuildConfig(os.Getenv(ConfigEnv))
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Config = config
+	}
+
+	config := GetBuildConfig()
+	if options.Config != nil {
+		if err := SetBuildConfig(options.Config); err != nil {
+			log.Fatal(err)
+		}
+		config = options.Config
+	}
+
 	r := xapp.NewGin()
+	// 只信任 SetTrustedProxies 设置的代理, 其他客户端无法通过 X-Forwarded-* 伪造 ClientIP 和页面地址
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal(err)
+	}
 
-	r.Static("/assets", "build")
+	// 带内容 hash 的产物长期缓存, 优先返回预压缩文件
+	assets := r.Group("/assets", AssetCacheMiddleware())
+	assetsHandler := StaticAssets(lazyBuildFS{})
+	assets.GET("/*filepath", assetsHandler)
+	assets.HEAD("/*filepath", assetsHandler)
 
 	// 设置模板渲染器
-	var opts []func(*TemplateOptions)
+	templateOpts := []func(*TemplateOptions){WithBuildConfig(config)}
 	// if !xapp.IsDev() {
-	// 	opts = append(opts, WithCache(NewTemplateCache()))
+	// 	templateOpts = append(templateOpts, WithCache(NewTemplateCache()))
 	// }
-	r.HTMLRender = CreateTemplateRenderer(opts...)
+	r.HTMLRender = CreateTemplateRenderer(templateOpts...)
+	renderer := r.HTMLRender.(*TemplateRenderer)
+
+	r.Use(SetRendererContextMiddleware(renderer))
+
+	// SSR 响应压缩
+	r.Use(CompressMiddleware(DefaultCompressMinSize))
 
-	r.Use(SetRendererContextMiddleware(r.HTMLRender.(*TemplateRenderer)))
+	// 约定的 NotFound / Error 页面
+	r.Use(RecoveryMiddleware(renderer))
+	r.NoRoute(NoRouteHandler(renderer))
 
 	// CORS 配置
 	corsConfig := cors.Config{
@@ -72,7 +125,7 @@ func Gin() *gin.Engine {
 	}
 
 	if xapp.IsDev() {
-		setupDev(r)
+		setupDev(r, config)
 	}
 
 	return r
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// sourceMap source map v3 中定位原始位置需要的字段
+type sourceMap struct {
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Mappings       string   `json:"mappings"`
+}
+
+// sourcePosition 原始源码中的位置, Line 从 1 开始, Column 从 0 开始
+type sourcePosition struct {
+	Source string
+	Line   int
+	Column int
+	// sourcesContent 中的源码, 没有时为空
+	Content string
+}
+
+func parseSourceMap(content []byte) (*sourceMap, error) {
+	var m sourceMap
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// lookup 查找生成代码中 line (从 1 开始)、column (从 0 开始) 对应的原始位置
+// 使用该行中不超过 column 的最后一个映射
+func (m *sourceMap) lookup(line, column int) (sourcePosition, bool) {
+	var (
+		source, origLine, origColumn int
+		found                        bool
+		pos                          sourcePosition
+	)
+
+	for i, group := range strings.Split(m.Mappings, ";") {
+		if i >= line {
+			break
+		}
+		genColumn := 0
+		for _, segment := range strings.Split(group, ",") {
+			if segment == "" {
+				continue
+			}
+			values, ok := decodeVLQ(segment)
+			if !ok || len(values) == 0 {
+				continue
+			}
+			// 只有 1 个字段的片段没有原始位置, 但生成列仍然是相对于前一个片段的偏移
+			genColumn += values[0]
+			if len(values) < 4 {
+				continue
+			}
+			source += values[1]
+			origLine += values[2]
+			origColumn += values[3]
+
+			if i == line-1 && genColumn <= column && source >= 0 && source < len(m.Sources) {
+				found = true
+				pos = sourcePosition{Source: m.Sources[source], Line: origLine + 1, Column: origColumn}
+				if source < len(m.SourcesContent) {
+					pos.Content = m.SourcesContent[source]
+				}
+			}
+		}
+	}
+	return pos, found
+}
+
+const base64VLQChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ 解码一个 mappings 片段中的 base64 VLQ 数值
+func decodeVLQ(segment string) ([]int, bool) {
+	var values []int
+	value, shift := 0, 0
+	for i := 0; i < len(segment); i++ {
+		digit := strings.IndexByte(base64VLQChars, segment[i])
+		if digit < 0 {
+			return nil, false
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	return values, shift == 0
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    []int
+		ok      bool
+	}{
+		{"A", []int{0}, true},
+		{"C", []int{1}, true},
+		{"D", []int{-1}, true},
+		{"AAAA", []int{0, 0, 0, 0}, true},
+		{"SAAQ", []int{9, 0, 0, 8}, true},
+		{"gB", []int{16}, true},
+		{"hB", []int{-16}, true},
+		{"2HwB", []int{123, 24}, true},
+		{"g", nil, false},
+		{"A!", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.segment, func(t *testing.T) {
+			got, ok := decodeVLQ(tt.segment)
+			if ok != tt.ok {
+				t.Fatalf("decodeVLQ(%q) ok = %v, want %v", tt.segment, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeVLQ(%q) = %v, want %v", tt.segment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	m := &sourceMap{
+		Sources:        []string{"a.ts", "b.ts"},
+		SourcesContent: []string{"const a = 1", ""},
+		// 第 1 行: 列 0 -> a.ts 1:0, 列 10 -> a.ts 1:6
+		// 第 2 行: 列 4 没有原始位置, 列 8 (4+4) -> b.ts 3:2
+		// 第 3 行: 列 2 -> b.ts 4:0
+		Mappings: "AAAA,UAAM;I,ICEJ;EACF",
+	}
+
+	tests := []struct {
+		name         string
+		line, column int
+		want         sourcePosition
+		found        bool
+	}{
+		{"first segment", 1, 0, sourcePosition{Source: "a.ts", Line: 1, Column: 0, Content: "const a = 1"}, true},
+		{"between segments", 1, 9, sourcePosition{Source: "a.ts", Line: 1, Column: 0, Content: "const a = 1"}, true},
+		{"second segment", 1, 20, sourcePosition{Source: "a.ts", Line: 1, Column: 6, Content: "const a = 1"}, true},
+		{"before one-field segment", 2, 3, sourcePosition{}, false},
+		{"one-field segment has no source", 2, 5, sourcePosition{}, false},
+		{"after one-field segment", 2, 8, sourcePosition{Source: "b.ts", Line: 3, Column: 2}, true},
+		{"third line", 3, 2, sourcePosition{Source: "b.ts", Line: 4, Column: 0}, true},
+		{"before third line segment", 3, 1, sourcePosition{}, false},
+		{"line without mappings", 4, 0, sourcePosition{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := m.lookup(tt.line, tt.column)
+			if found != tt.found || got != tt.want {
+				t.Errorf("lookup(%d, %d) = %+v, %v, want %+v, %v", tt.line, tt.column, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
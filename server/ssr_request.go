@@ -0,0 +1,261 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ssrRequestKey = "goreact_ssr_request"
+
+// SSRHeaderAllowlist 暴露给 SSR 运行时和客户端的请求头 (小写)
+// 以 sec-ch- 开头的 client hints 会单独放到 ClientHints 中
+var SSRHeaderAllowlist = []string{
+	"accept",
+	"accept-language",
+	"dnt",
+	"referer",
+	"save-data",
+	"sec-fetch-dest",
+	"sec-fetch-mode",
+	"sec-fetch-site",
+	"user-agent",
+	"x-requested-with",
+}
+
+// SSRCookieAllowlist 暴露给 SSR 运行时的 cookie, 默认为空
+// cookie 只在服务端渲染时可用, 不会写入页面状态, 客户端需要时自行读取 document.cookie
+var SSRCookieAllowlist = []string{}
+
+// 可以通过 X-Forwarded-Host、X-Forwarded-Proto 和 X-Forwarded-Port 指定请求地址的代理, 默认为空, 不信任任何代理
+var (
+	trustedProxies    []string
+	trustedProxyCIDRs []*net.IPNet
+)
+
+// SetTrustedProxies 设置信任的反向代理, 每一项为 IP 或 CIDR, 例如 10.0.0.0/8
+// Gin 创建的 engine 同时使用该列表计算 ClientIP, 需要在 Gin 之前调用
+func SetTrustedProxies(proxies []string) error {
+	cidrs, err := parseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies = proxies
+	trustedProxyCIDRs = cidrs
+	return nil
+}
+
+// parseTrustedProxies 解析信任的代理, 单个 IP 视为只包含该地址的网段
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// SSRRequest 暴露给组件的请求信息, 服务端渲染和客户端 hydrate 使用同一份数据
+// Cookies 只在服务端渲染时可用, 不会序列化到页面状态中
+type SSRRequest struct {
+	Href        string            `json:"href"`
+	Origin      string            `json:"origin"`
+	Protocol    string            `json:"protocol"`
+	Host        string            `json:"host"`
+	Hostname    string            `json:"hostname"`
+	Port        string            `json:"port"`
+	Pathname    string            `json:"pathname"`
+	Search      string            `json:"search"`
+	Hash        string            `json:"hash"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers"`
+	Cookies     map[string]string `json:"cookies" goreact:"server"`
+	UserAgent   string            `json:"userAgent"`
+	ClientHints map[string]string `json:"clientHints"`
+}
+
+// GetSSRRequest 获取当前请求的 SSRRequest, 同一请求内只计算一次
+func GetSSRRequest(c *gin.Context) *SSRRequest {
+	if v, ok := c.Get(ssrRequestKey); ok {
+		if req, ok := v.(*SSRRequest); ok {
+			return req
+		}
+	}
+
+	req := newSSRRequest(c)
+	c.Set(ssrRequestKey, req)
+	return req
+}
+
+func newSSRRequest(c *gin.Context) *SSRRequest {
+	r := c.Request
+
+	trusted := fromTrustedProxy(c)
+	scheme := requestScheme(c, trusted)
+	host := requestHost(c, trusted)
+	hostname, port := splitHostPort(host)
+	if forwardedPort := firstHeaderValue(c, "X-Forwarded-Port"); trusted && forwardedPort != "" && port == "" {
+		if !(scheme == "https" && forwardedPort == "443") && !(scheme == "http" && forwardedPort == "80") {
+			port = forwardedPort
+			host = net.JoinHostPort(hostname, port)
+		}
+	}
+
+	search := ""
+	if r.URL.RawQuery != "" {
+		search = "?" + r.URL.RawQuery
+	}
+
+	origin := fmt.Sprintf("%s://%s", scheme, host)
+
+	req := &SSRRequest{
+		Href:        origin + r.URL.EscapedPath() + search,
+		Origin:      origin,
+		Protocol:    scheme + ":",
+		Host:        host,
+		Hostname:    hostname,
+		Port:        port,
+		Pathname:    r.URL.Path,
+		Search:      search,
+		Hash:        "", // 浏览器不会把 hash 发送到服务端
+		Method:      r.Method,
+		Headers:     map[string]string{},
+		Cookies:     map[string]string{},
+		UserAgent:   r.UserAgent(),
+		ClientHints: map[string]string{},
+	}
+
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		value := strings.Join(values, ", ")
+		if strings.HasPrefix(lower, "sec-ch-") {
+			req.ClientHints[lower] = value
+			continue
+		}
+		for _, allowed := range SSRHeaderAllowlist {
+			if lower == allowed {
+				req.Headers[lower] = value
+				break
+			}
+		}
+	}
+
+	for _, cookie := range r.Cookies() {
+		if isAllowedCookie(cookie.Name) {
+			req.Cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	return req
+}
+
+// fromTrustedProxy 请求的对端是否是 SetTrustedProxies 设置的代理, 无法解析对端地址时不信任
+func fromTrustedProxy(c *gin.Context) bool {
+	remoteIP := net.ParseIP(c.RemoteIP())
+	if remoteIP == nil {
+		return false
+	}
+	for _, cidr := range trustedProxyCIDRs {
+		if cidr.Contains(remoteIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestScheme 根据 TLS 和 X-Forwarded-Proto 推断请求协议, 只有信任的代理才能通过请求头指定
+func requestScheme(c *gin.Context, trusted bool) string {
+	if proto := strings.ToLower(firstHeaderValue(c, "X-Forwarded-Proto")); trusted && (proto == "http" || proto == "https") {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestHost 根据 X-Forwarded-Host 和 Host 推断请求域名, 只有信任的代理才能通过请求头指定
+func requestHost(c *gin.Context, trusted bool) string {
+	if host := firstHeaderValue(c, "X-Forwarded-Host"); trusted && host != "" {
+		return host
+	}
+	return c.Request.Host
+}
+
+// firstHeaderValue 获取代理链路中第一个 (离客户端最近的) 值
+func firstHeaderValue(c *gin.Context, name string) string {
+	value := c.GetHeader(name)
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+func splitHostPort(host string) (string, string) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host, ""
+	}
+	return hostname, port
+}
+
+func isAllowedCookie(name string) bool {
+	for _, allowed := range SSRCookieAllowlist {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ssrWindowScript SSR 运行时中 window 即全局对象, 与浏览器一致, window.goreact 和 goreact 是同一个对象
+const ssrWindowScript = "globalThis.window = globalThis;"
+
+// ssrRequestScript 生成注入 SSR 运行时的请求信息和 window.location
+func ssrRequestScript(req *SSRRequest) (string, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+	%s
+	globalThis.__GOREACT_REQUEST__ = %s;
+	globalThis.goreact = globalThis.goreact || {};
+	globalThis.goreact.request = globalThis.__GOREACT_REQUEST__;
+	globalThis.window.location = (function (req) {
+	  return {
+	    hostname: req.hostname,
+	    protocol: req.protocol,
+	    origin: req.origin,
+	    search: req.search,
+	    pathname: req.pathname,
+	    hash: req.hash,
+	    port: req.port,
+	    host: req.host,
+	    href: req.href,
+	    toString: function () { return req.href; },
+	    assign: function(url) { console.log("Location assign:", url); },
+	    replace: function(url) { console.log("Location replace:", url); },
+	    reload: function(force) { console.log("Location reload:", force); }
+	  };
+	})(globalThis.__GOREACT_REQUEST__);
+	`, ssrWindowScript, reqJSON), nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestSSRContext(t *testing.T, trustedProxies []string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if err := SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+	c.Request = httptest.NewRequest("GET", "http://example.com/products?id=1", nil)
+	c.Request.RemoteAddr = "10.0.0.1:4321"
+	c.Request.Header.Set("X-Forwarded-For", "203.0.113.9")
+	c.Request.Header.Set("X-Forwarded-Proto", "https")
+	c.Request.Header.Set("X-Forwarded-Host", "evil.test")
+	c.Request.Header.Set("X-Forwarded-Port", "8443")
+	return c, w
+}
+
+func TestSSRRequestForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		prepare func(r *http.Request)
+		href    string
+	}{
+		{"untrusted", nil, nil, "http://example.com/products?id=1"},
+		{"trusted", []string{"10.0.0.0/8"}, nil, "https://evil.test:8443/products?id=1"},
+		{"trusted ip", []string{"10.0.0.1"}, nil, "https://evil.test:8443/products?id=1"},
+		{"other proxy", []string{"192.168.0.0/16"}, nil, "http://example.com/products?id=1"},
+		{
+			"trusted without X-Forwarded-For", []string{"10.0.0.0/8"},
+			func(r *http.Request) { r.Header.Del("X-Forwarded-For") },
+			"https://evil.test:8443/products?id=1",
+		},
+		{
+			"unparsable remote address", []string{"0.0.0.0/0"},
+			func(r *http.Request) { r.RemoteAddr = "@" },
+			"http://example.com/products?id=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestSSRContext(t, tt.proxies)
+			if tt.prepare != nil {
+				tt.prepare(c.Request)
+			}
+			if got := newSSRRequest(c).Href; got != tt.href {
+				t.Errorf("Href = %q, want %q", got, tt.href)
+			}
+		})
+	}
+}
+
+func TestSSRRequestCookies(t *testing.T) {
+	c, _ := newTestSSRContext(t, nil)
+	c.Request.Header.Set("Cookie", "session_token=secret; theme=dark")
+
+	defer func(allowlist []string) { SSRCookieAllowlist = allowlist }(SSRCookieAllowlist)
+
+	SSRCookieAllowlist = nil
+	if cookies := newSSRRequest(c).Cookies; len(cookies) != 0 {
+		t.Fatalf("Cookies = %v, want none by default", cookies)
+	}
+
+	SSRCookieAllowlist = []string{"theme"}
+	req := newSSRRequest(c)
+	if len(req.Cookies) != 1 || req.Cookies["theme"] != "dark" {
+		t.Fatalf("Cookies = %v, want only theme", req.Cookies)
+	}
+
+	state, err := SerializeState(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(state), "dark") || strings.Contains(string(state), `"cookies"`) {
+		t.Errorf("cookies leaked into page state: %s", state)
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		proxies []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"10.0.0.1", "192.168.0.0/16", "::1", "fd00::/8"}, false},
+		{[]string{"proxy.internal"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+	}
+
+	for _, tt := range tests {
+		err := SetTrustedProxies(tt.proxies)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetTrustedProxies(%q) error = %v, wantErr %v", tt.proxies, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSSRRuntimeSharesWindow(t *testing.T) {
+	c, _ := newTestSSRContext(t, nil)
+	c.Request.Header.Set("Cookie", "theme=dark")
+	defer func(allowlist []string) { SSRCookieAllowlist = allowlist }(SSRCookieAllowlist)
+	SSRCookieAllowlist = []string{"theme"}
+
+	req := newSSRRequest(c)
+	requestScript, err := ssrRequestScript(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := SerializeState(&PageState{Props: map[string]any{"id": 1}, Request: req})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewV8JsEngine()
+	defer engine.Close()
+	for _, script := range []string{requestScript, pageStateScript(state), ssrResponseScript} {
+		if _, err := engine.RunScript(script, "test.js"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := engine.RunScript(`JSON.stringify([
+		window === globalThis,
+		window.goreact === goreact,
+		window.goreact.request.cookies,
+		__GOREACT_REQUEST__ === goreact.request,
+		typeof window.goreact.response,
+		window.location.href,
+		window.INITIAL_PROPS.id
+	])`, "check.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[true,true,{"theme":"dark"},true,"object","http://example.com/products?id=1",1]`
+	if got != want {
+		t.Errorf("runtime = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/daodao97/xgo/xlog"
+	"github.com/gin-gonic/gin"
+)
+
+const ssrResponseKey = "goreact_ssr_response"
+
+// ssrResponseScript 注入到 SSR 运行时的响应控制 API
+// 组件在服务端渲染期间可调用:
+//
+//	goreact.response.setStatus(404)
+//	goreact.response.notFound()
+//	goreact.response.redirect("/login", 302)
+//	goreact.response.setHeader("Cache-Control", "no-store")
+//	goreact.response.setCookie("name", "value", {path: "/", maxAge: 3600, httpOnly: true})
+const ssrResponseScript = `
+globalThis.__GOREACT_RESPONSE__ = {status: 0, redirect: "", headers: {}, cookies: []};
+globalThis.goreact = globalThis.goreact || {};
+globalThis.goreact.response = (function (res) {
+  return {
+    setStatus: function (status) { res.status = status; },
+    notFound: function () { res.status = 404; },
+    redirect: function (url, status) { res.redirect = String(url); res.status = status || 302; },
+    setHeader: function (name, value) { res.headers[name] = String(value); },
+    setCookie: function (name, value, options) {
+      var cookie = Object.assign({}, options || {});
+      cookie.name = name;
+      cookie.value = String(value);
+      res.cookies.push(cookie);
+    }
+  };
+})(globalThis.__GOREACT_RESPONSE__);
+`
+
+const ssrResponseReadScript = `JSON.stringify(globalThis.__GOREACT_RESPONSE__)`
+
+// SSRResponse 组件在服务端渲染期间设置的响应控制信息
+type SSRResponse struct {
+	Status   int               `json:"status"`
+	Redirect string            `json:"redirect"`
+	Headers  map[string]string `json:"headers"`
+	Cookies  []SSRCookie       `json:"cookies"`
+}
+
+// SSRCookie 组件设置的 cookie
+type SSRCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Domain   string `json:"domain"`
+	MaxAge   int    `json:"maxAge"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"httpOnly"`
+	SameSite string `json:"sameSite"`
+}
+
+// parseSSRResponse 解析 SSR 运行时回传的响应控制信息
+func parseSSRResponse(raw string) (*SSRResponse, error) {
+	resp := &SSRResponse{}
+	if err := json.Unmarshal([]byte(raw), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// IsEmpty 组件是否没有设置任何响应信息
+func (r *SSRResponse) IsEmpty() bool {
+	return r == nil || (r.Status == 0 && r.Redirect == "" && len(r.Headers) == 0 && len(r.Cookies) == 0)
+}
+
+// IsRedirect 组件是否要求重定向
+func (r *SSRResponse) IsRedirect() bool {
+	return r != nil && r.Redirect != ""
+}
+
+// Apply 在写入 body 之前把响应信息应用到 ResponseWriter
+func (r *SSRResponse) Apply(w http.ResponseWriter) {
+	if r.IsEmpty() {
+		return
+	}
+
+	header := w.Header()
+	for name, value := range r.Headers {
+		header.Set(name, value)
+	}
+
+	for _, cookie := range r.Cookies {
+		http.SetCookie(w, cookie.httpCookie())
+	}
+
+	if r.IsRedirect() {
+		status := r.Status
+		if status < 300 || status > 399 {
+			status = http.StatusFound
+		}
+		header.Set("Location", r.Redirect)
+		w.WriteHeader(status)
+		return
+	}
+
+	// 1xx 是中间响应, 不能作为页面的最终状态码
+	switch {
+	case r.Status >= 200 && r.Status <= 599:
+		w.WriteHeader(r.Status)
+	case r.Status != 0:
+		xlog.Warn("ignore invalid ssr response status", xlog.Int("status", r.Status))
+	}
+}
+
+func (c SSRCookie) httpCookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		MaxAge:   c.MaxAge,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+
+	switch strings.ToLower(c.SameSite) {
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+
+	return cookie
+}
+
+// setSSRResponse 保存本次请求的 SSR 响应信息
+func setSSRResponse(c *gin.Context, resp *SSRResponse) {
+	c.Set(ssrResponseKey, resp)
+}
+
+// GetSSRResponse 获取本次请求组件设置的响应信息
+func GetSSRResponse(c *gin.Context) *SSRResponse {
+	if v, ok := c.Get(ssrResponseKey); ok {
+		if resp, ok := v.(*SSRResponse); ok {
+			return resp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSSRResponseApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *SSRResponse
+		status   int
+		location string
+	}{
+		{"empty", &SSRResponse{}, http.StatusOK, ""},
+		{"not found", &SSRResponse{Status: http.StatusNotFound}, http.StatusNotFound, ""},
+		{"server error", &SSRResponse{Status: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, ""},
+		{"informational status", &SSRResponse{Status: http.StatusEarlyHints}, http.StatusOK, ""},
+		{"continue", &SSRResponse{Status: http.StatusContinue}, http.StatusOK, ""},
+		{"out of range", &SSRResponse{Status: 600}, http.StatusOK, ""},
+		{"redirect", &SSRResponse{Redirect: "/login", Status: http.StatusMovedPermanently}, http.StatusMovedPermanently, "/login"},
+		{"redirect with invalid status", &SSRResponse{Redirect: "/login", Status: http.StatusOK}, http.StatusFound, "/login"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.response.Apply(w)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/daodao97/xgo/xlog"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// DefaultTargets 客户端默认的目标环境, 支持原生 ES module 和动态 import 的浏览器
+var DefaultTargets = []string{"es2020", "chrome87", "edge88", "firefox78", "safari14"}
+
+// DefaultServerTargets SSR bundle 在 v8 中执行, 默认不降级语法
+var DefaultServerTargets = []string{"esnext"}
+
+var esVersions = map[string]esbuild.Target{
+	"esnext": esbuild.ESNext,
+	"es5":    esbuild.ES5,
+	"es6":    esbuild.ES2015,
+	"es2015": esbuild.ES2015,
+	"es2016": esbuild.ES2016,
+	"es2017": esbuild.ES2017,
+	"es2018": esbuild.ES2018,
+	"es2019": esbuild.ES2019,
+	"es2020": esbuild.ES2020,
+	"es2021": esbuild.ES2021,
+	"es2022": esbuild.ES2022,
+	"es2023": esbuild.ES2023,
+	"es2024": esbuild.ES2024,
+}
+
+// esbuild 的引擎名称, 以及 browserslist 中对应的名称
+var engineNames = map[string]esbuild.EngineName{
+	"chrome":   esbuild.EngineChrome,
+	"and_chr":  esbuild.EngineChrome,
+	"deno":     esbuild.EngineDeno,
+	"edge":     esbuild.EngineEdge,
+	"firefox":  esbuild.EngineFirefox,
+	"ff":       esbuild.EngineFirefox,
+	"and_ff":   esbuild.EngineFirefox,
+	"hermes":   esbuild.EngineHermes,
+	"ie":       esbuild.EngineIE,
+	"explorer": esbuild.EngineIE,
+	"ios":      esbuild.EngineIOS,
+	"ios_saf":  esbuild.EngineIOS,
+	"node":     esbuild.EngineNode,
+	"opera":    esbuild.EngineOpera,
+	"rhino":    esbuild.EngineRhino,
+	"safari":   esbuild.EngineSafari,
+}
+
+var (
+	// esbuild 格式: safari14、chrome100.1
+	engineTargetPattern = regexp.MustCompile(`^([a-z_]+?)(\d+(?:\.\d+)*)$`)
+	// browserslist 格式: safari >= 14、ios_saf 15.4
+	browserslistPattern = regexp.MustCompile(`^([a-z_]+)\s*(?:>=\s*)?(\d+(?:\.\d+)*)$`)
+)
+
+// esbuild 无法为目标环境降级语法时的警告, 例如正则表达式的新 flag 或 CSS 嵌套
+var targetWarningIDs = []string{"unsupported-regexp", "unsupported-css-property", "unsupported-css-nesting"}
+
+// buildTargets 解析后的目标环境
+type buildTargets struct {
+	list    []string
+	target  esbuild.Target
+	engines []esbuild.Engine
+}
+
+// parseTargets 解析目标环境列表, 每一项可以是
+//
+//	ES 版本:       es2020、esnext
+//	esbuild 引擎:  safari14、chrome100、ios15.4
+//	browserslist:  safari >= 14、ios_saf 15.4, 多项可以用逗号分隔
+//
+// 同一个引擎出现多次时取最低版本. 依赖使用数据的 browserslist 查询 (last 2 versions、> 0.5%、defaults) 不支持
+func parseTargets(list []string) (buildTargets, error) {
+	targets := buildTargets{target: esbuild.ESNext}
+	versions := map[esbuild.EngineName]string{}
+	esVersion := ""
+
+	for _, item := range list {
+		for _, entry := range strings.Split(item, ",") {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			if entry == "" {
+				continue
+			}
+			targets.list = append(targets.list, entry)
+
+			if target, ok := esVersions[entry]; ok {
+				if esVersion == "" || esOrder(entry) < esOrder(esVersion) {
+					esVersion = entry
+					targets.target = target
+				}
+				continue
+			}
+
+			match := engineTargetPattern.FindStringSubmatch(entry)
+			if match == nil {
+				match = browserslistPattern.FindStringSubmatch(entry)
+			}
+			if match == nil {
+				return buildTargets{}, fmt.Errorf("unsupported target %q, expected an ES version (es2020), an engine (safari14) or a minimum browser version (safari >= 14)", entry)
+			}
+
+			engine, ok := engineNames[match[1]]
+			if !ok {
+				return buildTargets{}, fmt.Errorf("unsupported target %q: unknown engine %q", entry, match[1])
+			}
+			if prev, ok := versions[engine]; !ok || compareVersions(match[2], prev) < 0 {
+				versions[engine] = match[2]
+			}
+		}
+	}
+
+	for engine, version := range versions {
+		targets.engines = append(targets.engines, esbuild.Engine{Name: engine, Version: version})
+	}
+	slices.SortFunc(targets.engines, func(a, b esbuild.Engine) int {
+		return int(a.Name) - int(b.Name)
+	})
+	return targets, nil
+}
+
+// esOrder ES 版本的先后顺序, esnext 最新
+func esOrder(version string) int {
+	switch version {
+	case "esnext":
+		return 1 << 30
+	case "es5":
+		return 5
+	case "es6":
+		return 2015
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(version, "es"))
+	return n
+}
+
+// compareVersions 按数字比较 14.1 和 14 这样的版本号
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// apply 设置构建的目标环境, 同时作用于 JS 和 CSS
+func (t buildTargets) apply(options *esbuild.BuildOptions) {
+	options.Target = t.target
+	options.Engines = t.engines
+
+	// esbuild 默认以 debug 级别记录无法降级的正则表达式, 提升为警告
+	if options.LogOverride == nil {
+		options.LogOverride = map[string]esbuild.LogLevel{}
+	}
+	for _, id := range targetWarningIDs {
+		options.LogOverride[id] = esbuild.LogLevelWarning
+	}
+}
+
+// browserslist 中对应 esbuild 引擎的浏览器名称, 没有对应名称的引擎 (deno、hermes、rhino) 不参与查询
+var browserslistNames = map[esbuild.EngineName]string{
+	esbuild.EngineChrome:  "chrome",
+	esbuild.EngineEdge:    "edge",
+	esbuild.EngineFirefox: "firefox",
+	esbuild.EngineIE:      "ie",
+	esbuild.EngineIOS:     "ios_saf",
+	esbuild.EngineNode:    "node",
+	esbuild.EngineOpera:   "opera",
+	esbuild.EngineSafari:  "safari",
+}
+
+// browserslist 将引擎版本转为 browserslist 查询, 例如 chrome >= 87, safari >= 14
+// ES 版本在 browserslist 中没有对应的查询, 只有 ES 版本时返回空字符串
+func (t buildTargets) browserslist() string {
+	var queries []string
+	for _, engine := range t.engines {
+		if name, ok := browserslistNames[engine.Name]; ok {
+			queries = append(queries, name+" >= "+engine.Version)
+		}
+	}
+	return strings.Join(queries, ", ")
+}
+
+func (t buildTargets) String() string {
+	return strings.Join(t.list, ", ")
+}
+
+// isTargetMessage 是否是目标环境不支持某个语法的诊断信息
+func isTargetMessage(message esbuild.Message) bool {
+	return slices.Contains(targetWarningIDs, message.ID) || strings.Contains(message.Text, "configured target environment")
+}
+
+// annotate 为无法降级的语法添加目标环境的说明
+func (t buildTargets) annotate(messages []esbuild.Message) []esbuild.Message {
+	for i, message := range messages {
+		if isTargetMessage(message) {
+			messages[i].Notes = append(message.Notes, esbuild.Note{
+				Text: fmt.Sprintf("Build targets: %s. Raise the targets in goreact.yaml or avoid this syntax.", t),
+			})
+		}
+	}
+	return messages
+}
+
+// logWarnings 输出无法为目标环境降级的语法, 这类代码会原样输出, 在较旧的浏览器中可能无法运行
+func (t buildTargets) logWarnings(name string, warnings []esbuild.Message) {
+	var messages []esbuild.Message
+	for _, warning := range warnings {
+		if isTargetMessage(warning) {
+			messages = append(messages, warning)
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	formatted := esbuild.FormatMessages(t.annotate(messages), esbuild.FormatMessagesOptions{Kind: esbuild.WarningMessage})
+	xlog.Warn("syntax not supported by build targets",
+		xlog.String("build", name),
+		xlog.String("targets", t.String()),
+		xlog.String("warnings", strings.TrimRight(strings.Join(formatted, ""), "\n")),
+	)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name         string
+		list         []string
+		target       esbuild.Target
+		engines      []esbuild.Engine
+		browserslist string
+		wantErr      bool
+	}{
+		{
+			name:   "empty",
+			target: esbuild.ESNext,
+		},
+		{
+			name:   "lowest es version wins",
+			list:   []string{"es2022", "ES2017", "esnext"},
+			target: esbuild.ES2017,
+		},
+		{
+			name:   "es6 alias",
+			list:   []string{"es2016", "es6"},
+			target: esbuild.ES2015,
+		},
+		{
+			name:   "esbuild engines",
+			list:   []string{"es2020", "safari14", "chrome100.1"},
+			target: esbuild.ES2020,
+			engines: []esbuild.Engine{
+				{Name: esbuild.EngineChrome, Version: "100.1"},
+				{Name: esbuild.EngineSafari, Version: "14"},
+			},
+			browserslist: "chrome >= 100.1, safari >= 14",
+		},
+		{
+			name:   "browserslist entries separated by commas",
+			list:   []string{"safari >= 14, ios_saf 15.4", "ff >= 78"},
+			target: esbuild.ESNext,
+			engines: []esbuild.Engine{
+				{Name: esbuild.EngineFirefox, Version: "78"},
+				{Name: esbuild.EngineIOS, Version: "15.4"},
+				{Name: esbuild.EngineSafari, Version: "14"},
+			},
+			browserslist: "firefox >= 78, ios_saf >= 15.4, safari >= 14",
+		},
+		{
+			name:   "lowest engine version wins",
+			list:   []string{"safari14.1", "safari 14", "safari >= 15"},
+			target: esbuild.ESNext,
+			engines: []esbuild.Engine{
+				{Name: esbuild.EngineSafari, Version: "14"},
+			},
+			browserslist: "safari >= 14",
+		},
+		{
+			name:   "engines without browserslist name",
+			list:   []string{"deno1.40", "node18"},
+			target: esbuild.ESNext,
+			engines: []esbuild.Engine{
+				{Name: esbuild.EngineDeno, Version: "1.40"},
+				{Name: esbuild.EngineNode, Version: "18"},
+			},
+			browserslist: "node >= 18",
+		},
+		{
+			name:    "usage query",
+			list:    []string{"last 2 versions"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown engine",
+			list:    []string{"netscape4"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargets(tt.list)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTargets(%q) error = nil, want error", tt.list)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.target != tt.target {
+				t.Errorf("target = %v, want %v", got.target, tt.target)
+			}
+			if !reflect.DeepEqual(got.engines, tt.engines) {
+				t.Errorf("engines = %v, want %v", got.engines, tt.engines)
+			}
+			if query := got.browserslist(); query != tt.browserslist {
+				t.Errorf("browserslist() = %q, want %q", query, tt.browserslist)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"14", "14", 0},
+		{"14", "14.0", 0},
+		{"14.1", "14", 1},
+		{"9", "10", -1},
+		{"15.4", "15.10", -1},
+	}
+
+	for _, tt := range tests {
+		got := compareVersions(tt.a, tt.b)
+		if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+			t.Errorf("compareVersions(%q, %q) = %d, want sign of %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"html/template"
 	"log"
-	"os"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -20,6 +18,8 @@ import (
 
 type TemplateOptions struct {
 	Cache *TemplateCache
+	// 读取服务端 bundle 使用的构建配置, 为空时使用 SetBuildConfig 设置的配置
+	Config *BuildConfig
 }
 
 func WithCache(cache *TemplateCache) func(*TemplateOptions) {
@@ -28,6 +28,12 @@ func WithCache(cache *TemplateCache) func(*TemplateOptions) {
 	}
 }
 
+func WithBuildConfig(config *BuildConfig) func(*TemplateOptions) {
+	return func(options *TemplateOptions) {
+		options.Config = config
+	}
+}
+
 func CreateTemplateRenderer(opts ...func(*TemplateOptions)) render.HTMLRender {
 	tmpl := template.New("").Funcs(functions)
 
@@ -47,6 +53,7 @@ func CreateTemplateRenderer(opts ...func(*TemplateOptions)) render.HTMLRender {
 		templates:  tmpl,
 		ginContext: nil,
 		cache:      cache,
+		config:     options.Config,
 	}
 }
 
@@ -58,6 +65,15 @@ type TemplateRenderer struct {
 	templates  *template.Template
 	ginContext *gin.Context
 	cache      *TemplateCache
+	config     *BuildConfig
+}
+
+// buildConfig 渲染使用的构建配置
+func (t *TemplateRenderer) buildConfig() *BuildConfig {
+	if t.config != nil {
+		return t.config
+	}
+	return GetBuildConfig()
 }
 
 func (t *TemplateRenderer) SetGinContext(c *gin.Context) {
@@ -89,7 +105,17 @@ func (t *TemplateRenderer) CleanupGoroutineContext() {
 }
 
 func (t *TemplateRenderer) RenderReact(c *gin.Context, fragment string, data any) (template.HTML, error) {
-	reactContent, err := os.ReadFile(filepath.Join(globalConfig.BuildServerDir, fragment))
+	state, err := EncodePageState(c, data)
+	if err != nil {
+		return template.HTML(""), err
+	}
+
+	return t.renderReact(c, fragment, data, state)
+}
+
+// renderReact 使用已经序列化的页面状态渲染组件
+func (t *TemplateRenderer) renderReact(c *gin.Context, fragment string, data any, state []byte) (template.HTML, error) {
+	reactContent, err := readServerBundle(t.buildConfig(), fragment)
 	if err != nil {
 		return template.HTML(""), err
 	}
@@ -100,6 +126,9 @@ func (t *TemplateRenderer) RenderReact(c *gin.Context, fragment string, data any
 		xlog.Debug("RenderReact render end", xlog.String("path", c.Request.URL.Path), xlog.Any("fragment", fragment), xlog.Any("data", data), xlog.Any("time", time.Since(start)))
 	}()
 
+	// 清理同一请求中上一次渲染留下的响应信息
+	setSSRResponse(c, nil)
+
 	cacheKey, err := t.cache.GenerateKey(fragment, data)
 	if err == nil && t.cache != nil {
 		if cachedHTML, found := t.cache.Load(cacheKey); found {
@@ -116,12 +145,15 @@ func (t *TemplateRenderer) RenderReact(c *gin.Context, fragment string, data any
 	defer render.Close()
 
 	// 执行渲染
-	html, err := render.Ctx(c).Render(data)
+	html, err := render.Ctx(c).Render(state)
 	if err != nil {
 		return html, err
 	}
 
-	if t.cache != nil {
+	setSSRResponse(c, render.Response())
+
+	// 设置了状态码、重定向等响应信息的结果依赖请求, 不进入缓存
+	if t.cache != nil && render.Response().IsEmpty() {
 		if err := t.cache.Save(cacheKey, html); err != nil {
 			if err := t.cache.Save(cacheKey, html); err != nil {
 				xlog.Warn("Failed to save render result to cache", xlog.Any("error", err))
@@ -136,6 +168,7 @@ func (t *TemplateRenderer) RenderReact(c *gin.Context, fragment string, data any
 // name: index.html:Home.js
 // name: Home.js
 // name: Home
+// name: blog/Index (嵌套页面使用相对于 frontend/pages 的路径)
 func (t *TemplateRenderer) Instance(name string, data any) render.Render {
 	componentName := ""
 	templateName := ""
@@ -152,7 +185,7 @@ func (t *TemplateRenderer) Instance(name string, data any) render.Render {
 	}
 
 	componentName = strings.TrimSuffix(componentName, ".jsx")
-	if !strings.HasSuffix(componentName, ".js") {
+	if componentName != "" && !strings.HasSuffix(componentName, ".js") {
 		componentName = fmt.Sprintf("%s.js", componentName)
 	}
 
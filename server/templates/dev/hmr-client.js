@@ -0,0 +1,266 @@
+// goreact dev 模式的 HMR 客户端, 由 /hmr/client.js 提供
+// "hmr" 刷新页面, JSON 消息为不需要刷新的通知: 模块更新、类型检查结果、构建和渲染错误
+(function () {
+    var font = 'font:12px/1.5 ui-monospace,Menlo,monospace';
+    // 开启 Fast Refresh 时 /hmr/client.js 在前面打包了 React Refresh runtime
+    var Refresh = window.__goreactRefresh;
+
+    // 模块登记表, 客户端构建在每个前端模块末尾登记它导入的模块
+    var hmr = window.__GOREACT_HMR__ = {
+        // 模块 id 到命名空间, 替换后为最新的命名空间
+        modules: {},
+        // 模块 id 到导入它的模块
+        importers: {},
+        // 模块中 hook 调用的签名
+        signatures: {},
+
+        register: function (id, signature, deps) {
+            hmr.signatures[id] = signature;
+            deps.forEach(function (dep) {
+                var key = dep[0];
+                if (!(key in hmr.modules)) {
+                    hmr.modules[key] = dep[1];
+                    registerComponents(key);
+                }
+                (hmr.importers[key] = hmr.importers[key] || {})[id] = true;
+            });
+        },
+
+        // 更新模块中的 import, 以 ES module 的形式返回已经加载的模块
+        require: function (key) {
+            if (!(key in hmr.modules)) {
+                throw new Error('[goreact] ' + key + ' is not loaded on this page');
+            }
+            var ns = hmr.modules[key];
+            var exports = Object.defineProperty({}, '__esModule', { value: true });
+            Object.keys(ns).forEach(function (name) {
+                Object.defineProperty(exports, name, { enumerable: true, get: function () { return ns[name]; } });
+            });
+            return exports;
+        }
+    };
+
+    // 把模块导出的组件登记到 React Refresh, 相同 id 的新组件替换旧组件
+    // 未导出的组件不在命名空间中, 无法登记, 模块更新后会重新挂载
+    function registerComponents(id) {
+        var ns = hmr.modules[id];
+        if (!Refresh || !(id in hmr.signatures) || !ns) return;
+        Object.keys(ns).forEach(function (name) {
+            var value = ns[name];
+            if (Refresh.isLikelyComponentType(value)) {
+                Refresh.register(value, id + ' ' + name);
+                Refresh.setSignature(value, hmr.signatures[id]);
+            }
+        });
+    }
+
+    // 只导出组件的模块可以直接替换, 其他模块需要重新执行导入它的模块
+    function isBoundary(ns) {
+        var names = Object.keys(ns || {});
+        return names.length > 0 && names.every(function (name) {
+            return name === '__esModule' || Refresh.isLikelyComponentType(ns[name]);
+        });
+    }
+
+    function reload(reason) {
+        if (reason) console.log('[goreact] ' + reason + ', reloading');
+        window.location.reload();
+    }
+
+    // 替换地址变化的样式表, 新样式加载后再移除旧的, 避免闪烁
+    function updateStyles(styles) {
+        Array.prototype.forEach.call(document.querySelectorAll('link[rel="stylesheet"]'), function (link) {
+            var next = styles[link.getAttribute('href')];
+            if (!next) return;
+            var copy = link.cloneNode();
+            copy.href = next;
+            copy.onload = function () { link.remove(); };
+            link.parentNode.insertBefore(copy, link.nextSibling);
+        });
+    }
+
+    function moduleURL(id, timestamp) {
+        return '/hmr/module/' + id.split('/').map(encodeURIComponent).join('/') + '?t=' + timestamp;
+    }
+
+    // 从变动的模块向上找到 refresh boundary, 依次重新执行后刷新组件, 找不到时刷新页面
+    var updating = Promise.resolve();
+    function applyUpdate(update) {
+        updating = updating.then(function () {
+            updateStyles(update.styles || {});
+
+            var urls = {};
+            var queue = [];
+            (update.modules || []).forEach(function (m) {
+                urls[m.id] = m.url;
+                // 当前页面没有用到的模块不需要更新
+                if (m.id in hmr.modules) queue.push(m.id);
+            });
+            if (queue.length === 0) return;
+            if (!Refresh) return reload('fast refresh is disabled');
+
+            // 需要重新执行的模块, 以及它们之间的导入关系
+            var edges = {};
+            while (queue.length > 0) {
+                var id = queue.shift();
+                if (id in edges) continue;
+                edges[id] = [];
+                if (isBoundary(hmr.modules[id])) continue;
+                edges[id] = Object.keys(hmr.importers[id] || {});
+                if (edges[id].length === 0) return reload(id + ' is not a refresh boundary');
+                queue.push.apply(queue, edges[id]);
+            }
+
+            // 被导入的模块先执行, 导入它的模块才能读到新的命名空间
+            var order = [];
+            var visited = {};
+            function visit(id) {
+                if (visited[id]) return;
+                visited[id] = true;
+                edges[id].forEach(visit);
+                order.unshift(id);
+            }
+            Object.keys(edges).forEach(visit);
+
+            return order.reduce(function (done, id) {
+                return done.then(function () {
+                    return import(urls[id] || moduleURL(id, update.timestamp)).then(function (ns) {
+                        var boundary = isBoundary(hmr.modules[id]);
+                        hmr.modules[id] = ns;
+                        if (boundary && !isBoundary(ns)) throw new Error(id + ' is no longer a refresh boundary');
+                        registerComponents(id);
+                    });
+                });
+            }, Promise.resolve()).then(function () {
+                Refresh.performReactRefresh();
+                console.log('[goreact] updated ' + order.join(', '));
+            });
+        }).catch(function (err) {
+            console.error(err);
+            reload('hot update failed');
+        });
+    }
+
+    // 类型检查结果, 有错误时在页面底部显示, 不影响页面运行
+    function showTypeCheck(result) {
+        var panel = document.getElementById('goreact-typecheck');
+        var errors = (result.diagnostics || []).filter(function (d) { return d.category === 'error'; });
+        if (errors.length === 0) {
+            if (panel) panel.remove();
+            return;
+        }
+        if (!panel) {
+            panel = document.createElement('div');
+            panel.id = 'goreact-typecheck';
+            panel.style.cssText = 'position:fixed;left:0;right:0;bottom:0;max-height:40vh;overflow:auto;z-index:2147483646;' +
+                'background:#1f2937;color:#f9fafb;' + font + ';padding:8px 12px;border-top:3px solid #ef4444';
+            document.body.appendChild(panel);
+        }
+        panel.textContent = '';
+        var title = document.createElement('div');
+        title.style.cssText = 'font-weight:bold;color:#fca5a5;margin-bottom:4px';
+        title.textContent = 'TypeScript: ' + errors.length + ' error(s)';
+        panel.appendChild(title);
+        errors.forEach(function (d) {
+            var item = document.createElement('pre');
+            item.style.cssText = 'margin:0 0 4px;white-space:pre-wrap';
+            item.textContent = (d.file ? d.file + ':' + d.line + ':' + d.column + ' ' : '') + 'TS' + d.code + ': ' + d.message;
+            panel.appendChild(item);
+        });
+    }
+
+    function element(tag, css, text) {
+        var el = document.createElement(tag);
+        el.style.cssText = css;
+        if (text) el.textContent = text;
+        return el;
+    }
+
+    function clearErrors() {
+        var overlay = document.getElementById('goreact-error-overlay');
+        if (overlay) overlay.remove();
+    }
+
+    // 构建和渲染错误, 覆盖整个页面, 下一次构建成功时自动关闭
+    function showErrors(errors) {
+        clearErrors();
+        var overlay = element('div', 'position:fixed;inset:0;overflow:auto;z-index:2147483647;' +
+            'background:rgba(17,24,39,.96);color:#f9fafb;' + font + ';padding:24px 32px');
+        overlay.id = 'goreact-error-overlay';
+
+        var close = element('button', 'position:absolute;top:12px;right:16px;background:none;border:0;' +
+            'color:#9ca3af;font-size:20px;cursor:pointer', '×');
+        close.title = 'Close (Esc)';
+        close.onclick = clearErrors;
+        overlay.appendChild(close);
+
+        var titles = { build: 'Build failed', css: 'CSS build failed', render: 'Server render failed' };
+        errors.forEach(function (e) {
+            var item = element('div', 'margin-bottom:24px;padding:12px 16px;background:#111827;border-left:4px solid #ef4444');
+            item.appendChild(element('div', 'color:#fca5a5;font-weight:bold;margin-bottom:4px', titles[e.source] || 'Error'));
+            if (e.file) {
+                item.appendChild(element('div', 'color:#93c5fd;margin-bottom:8px', e.file + (e.line ? ':' + e.line + ':' + e.column : '')));
+            }
+            item.appendChild(element('pre', 'margin:0 0 8px;white-space:pre-wrap;font-size:14px', e.message));
+            if (e.frame) {
+                item.appendChild(element('pre', 'margin:0 0 8px;padding:8px 12px;background:#030712;color:#e5e7eb;overflow:auto', e.frame));
+            }
+            if (e.stack) {
+                var details = element('details', 'color:#9ca3af');
+                details.appendChild(element('summary', 'cursor:pointer', 'Stack trace'));
+                details.appendChild(element('pre', 'margin:4px 0 0;white-space:pre-wrap', e.stack));
+                item.appendChild(details);
+            }
+            overlay.appendChild(item);
+        });
+        document.body.appendChild(overlay);
+    }
+
+    document.addEventListener('keydown', function (e) {
+        if (e.key === 'Escape') clearErrors();
+    });
+
+    function onHMR(e) {
+        if (e.data && e.data.charAt(0) === '{') {
+            var message = JSON.parse(e.data);
+            if (message.type === 'update') applyUpdate(message);
+            if (message.type === 'typecheck') showTypeCheck(message);
+            if (message.type === 'error') showErrors(message.errors || []);
+            if (message.type === 'clear') clearErrors();
+            return;
+        }
+        window.location.reload();
+    }
+
+    var event = new EventSource("/hmr")
+
+    // 添加错误处理和重连逻辑
+    var reconnectInterval = 2000; // 初始重连间隔(毫秒)
+    var maxReconnectInterval = 30000; // 最大重连间隔
+
+    function onError() {
+        if (event.readyState === EventSource.CLOSED) {
+            // 连接已关闭，尝试重连
+            console.log('HMR连接已断开，尝试重连...');
+            setTimeout(function () {
+                // 关闭旧连接
+                event.close();
+                // 创建新连接
+                event = new EventSource("/hmr");
+                event.addEventListener('hmr', onHMR);
+                // 连接成功后刷新页面
+                event.addEventListener('open', function () {
+                    window.location.reload();
+                });
+                // 重新添加错误处理
+                event.addEventListener('error', onError);
+
+                // 增加重连间隔(指数退避)
+                reconnectInterval = Math.min(reconnectInterval * 1.5, maxReconnectInterval);
+            }, reconnectInterval);
+        }
+    }
+
+    event.addEventListener('error', onError);
+    event.addEventListener('hmr', onHMR)
+})();
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+// 按顺序查找的 TypeScript/JavaScript 项目配置, 先查找 RootDir, 其次 FrontendDir
+var tsconfigFiles = []string{"tsconfig.json", "jsconfig.json"}
+
+// 别名解析时尝试的扩展名和目录 index 文件
+var aliasExtensions = []string{".tsx", ".ts", ".jsx", ".js"}
+
+// pathAlias 一条路径别名, 与 tsconfig 的 compilerOptions.paths 语义相同
+//
+//	"@/*":         ["./frontend/*"]
+//	"~ui":         ["./frontend/components/ui/index.ts"]
+//	"@shared/*":   ["./shared/*", "./vendor/shared/*"]
+type pathAlias struct {
+	// 匹配的模式, 最多包含一个 *
+	pattern string
+	// 绝对路径, * 替换为匹配的部分, 按顺序尝试
+	targets []string
+	// 别名的来源, 用于诊断信息, 例如 tsconfig.json
+	source string
+}
+
+// match 匹配导入路径, 返回 * 匹配的部分
+func (a pathAlias) match(importPath string) (string, bool) {
+	prefix, suffix, wildcard := strings.Cut(a.pattern, "*")
+	if !wildcard {
+		return "", importPath == a.pattern
+	}
+	if len(importPath) < len(prefix)+len(suffix) || !strings.HasPrefix(importPath, prefix) || !strings.HasSuffix(importPath, suffix) {
+		return "", false
+	}
+	return importPath[len(prefix) : len(importPath)-len(suffix)], true
+}
+
+// catchAll 模式以 * 开头, 例如 "*": ["./types/*", "*"], 所有非相对路径的导入都会匹配
+func (a pathAlias) catchAll() bool {
+	return strings.HasPrefix(a.pattern, "*")
+}
+
+// filter 该别名对应的 esbuild OnResolve 过滤条件
+func (a pathAlias) filter() string {
+	prefix, _, wildcard := strings.Cut(a.pattern, "*")
+	if !wildcard {
+		return regexp.QuoteMeta(a.pattern) + "$"
+	}
+	return regexp.QuoteMeta(prefix)
+}
+
+// aliasesFromMap 将目录别名转换为路径别名, 例如 @ -> /app/frontend 对应 @/* -> /app/frontend/*
+func aliasesFromMap(aliases map[string]string, source string) []pathAlias {
+	result := make([]pathAlias, 0, len(aliases))
+	for alias, dir := range aliases {
+		result = append(result, pathAlias{
+			pattern: alias + "/*",
+			targets: []string{filepath.Join(dir, "*")},
+			source:  source,
+		})
+	}
+	return result
+}
+
+// mergeAliases 合并别名, 前面的优先, 模式相同时忽略后面的
+// 结果中精确匹配的别名在前, 通配别名按前缀长度从长到短排列, 与 TypeScript 的匹配顺序一致
+func mergeAliases(groups ...[]pathAlias) []pathAlias {
+	seen := map[string]bool{}
+	var merged []pathAlias
+	for _, group := range groups {
+		for _, alias := range group {
+			if seen[alias.pattern] {
+				continue
+			}
+			seen[alias.pattern] = true
+			merged = append(merged, alias)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		pi, _, wi := strings.Cut(merged[i].pattern, "*")
+		pj, _, wj := strings.Cut(merged[j].pattern, "*")
+		if wi != wj {
+			return !wi
+		}
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return merged[i].pattern < merged[j].pattern
+	})
+	return merged
+}
+
+// pathAliases 构建使用的别名: 配置中的 Aliases 优先, 其次是 tsconfig.json/jsconfig.json 中的 paths
+func (c *BuildConfig) pathAliases() ([]pathAlias, error) {
+	tsAliases, err := loadTsconfigAliases(c.RootDir, c.FrontendDir)
+	if err != nil {
+		return nil, err
+	}
+	return mergeAliases(aliasesFromMap(c.Aliases, "goreact config"), tsAliases), nil
+}
+
+// loadTsconfigAliases 读取第一个存在的 tsconfig.json/jsconfig.json 中的 paths, 没有配置文件时返回空
+func loadTsconfigAliases(dirs ...string) ([]pathAlias, error) {
+	file, ok := findTsconfig(dirs...)
+	if !ok {
+		return nil, nil
+	}
+
+	options, err := readTsconfig(file, nil)
+	if err != nil {
+		return nil, err
+	}
+	return options.aliases(filepath.Base(file)), nil
+}
+
+// findTsconfig 按顺序查找第一个存在的 tsconfig.json/jsconfig.json
+func findTsconfig(dirs ...string) (string, bool) {
+	for _, dir := range dirs {
+		for _, name := range tsconfigFiles {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); err == nil {
+				return file, true
+			}
+		}
+	}
+	return "", false
+}
+
+// tsconfigChain 别名依赖的配置文件: RootDir 和 FrontendDir 中所有候选的 tsconfig.json/jsconfig.json,
+// 以及生效的配置通过 extends 引用的文件. 候选文件不存在时也包含在内, 新建配置文件同样需要重新构建
+func (c *BuildConfig) tsconfigChain() []string {
+	var files []string
+	for _, dir := range []string{c.RootDir, c.FrontendDir} {
+		for _, name := range tsconfigFiles {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	file, ok := findTsconfig(c.RootDir, c.FrontendDir)
+	if !ok {
+		return files
+	}
+
+	// 解析失败由构建报告, 这里只收集已经读取到的文件
+	visited := map[string]bool{}
+	_, _ = readTsconfig(file, visited)
+	var extended []string
+	for name := range visited {
+		if !slices.Contains(files, name) {
+			extended = append(extended, name)
+		}
+	}
+	sort.Strings(extended)
+	return append(files, extended...)
+}
+
+// tsconfig 中与别名相关的配置
+type tsconfigPaths struct {
+	// 绝对路径, 没有设置 baseUrl 时为空
+	baseURL string
+	paths   map[string][]string
+	// paths 所在配置文件的目录, 没有 baseUrl 时 paths 相对于该目录
+	pathsDir string
+}
+
+// aliases 转换为路径别名
+func (t *tsconfigPaths) aliases(source string) []pathAlias {
+	base := t.baseURL
+	if base == "" {
+		base = t.pathsDir
+	}
+
+	result := make([]pathAlias, 0, len(t.paths))
+	for pattern, targets := range t.paths {
+		alias := pathAlias{pattern: pattern, source: source}
+		for _, target := range targets {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(base, filepath.FromSlash(target))
+			}
+			alias.targets = append(alias.targets, target)
+		}
+		result = append(result, alias)
+	}
+	return result
+}
+
+// readTsconfig 读取 tsconfig, 处理 extends, 子配置中的 baseUrl 和 paths 覆盖父配置
+func readTsconfig(file string, visited map[string]bool) (*tsconfigPaths, error) {
+	if visited == nil {
+		visited = map[string]bool{}
+	}
+	if visited[file] {
+		return nil, fmt.Errorf("%s: circular extends", file)
+	}
+	visited[file] = true
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Extends         json.RawMessage `json:"extends"`
+		CompilerOptions struct {
+			BaseURL *string             `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}
+	if err := json.Unmarshal(stripJSONC(content), &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	dir := filepath.Dir(file)
+	result := &tsconfigPaths{}
+
+	// extends 可以是字符串或数组, 后面的覆盖前面的
+	var extends []string
+	if len(raw.Extends) > 0 {
+		var single string
+		if err := json.Unmarshal(raw.Extends, &single); err == nil {
+			extends = []string{single}
+		} else if err := json.Unmarshal(raw.Extends, &extends); err != nil {
+			return nil, fmt.Errorf("parse %s: invalid extends", file)
+		}
+	}
+	for _, parent := range extends {
+		parentFile, ok := resolveTsconfigExtends(dir, parent)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot find extended config %q", file, parent)
+		}
+		inherited, err := readTsconfig(parentFile, visited)
+		if err != nil {
+			return nil, err
+		}
+		if inherited.baseURL != "" {
+			result.baseURL = inherited.baseURL
+		}
+		if inherited.paths != nil {
+			result.paths = inherited.paths
+			result.pathsDir = inherited.pathsDir
+		}
+	}
+
+	if raw.CompilerOptions.BaseURL != nil {
+		result.baseURL = filepath.Join(dir, filepath.FromSlash(*raw.CompilerOptions.BaseURL))
+	}
+	if raw.CompilerOptions.Paths != nil {
+		result.paths = raw.CompilerOptions.Paths
+		result.pathsDir = dir
+	}
+
+	for pattern := range result.paths {
+		if strings.Count(pattern, "*") > 1 {
+			return nil, fmt.Errorf("%s: path pattern %q can have at most one '*'", file, pattern)
+		}
+	}
+	return result, nil
+}
+
+// resolveTsconfigExtends 解析 extends, 支持相对路径和 node_modules 中的包
+func resolveTsconfigExtends(dir, extends string) (string, bool) {
+	var candidates []string
+	if strings.HasPrefix(extends, ".") || filepath.IsAbs(extends) {
+		base := extends
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(dir, filepath.FromSlash(extends))
+		}
+		candidates = append(candidates, base, base+".json")
+	} else {
+		for d := dir; ; d = filepath.Dir(d) {
+			base := filepath.Join(d, "node_modules", filepath.FromSlash(extends))
+			candidates = append(candidates, base, base+".json", filepath.Join(base, "tsconfig.json"))
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// stripJSONC 去掉 tsconfig 中允许的注释和尾随逗号
+func stripJSONC(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '"':
+			// 字符串原样保留
+			start := i
+			for i++; i < len(content) && content[i] != '"'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+			out = append(out, content[start:min(i+1, len(content))]...)
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == ',':
+			// 后面只有空白和注释时是尾随逗号
+			j := skipJSONCSpace(content, i+1)
+			if j < len(content) && (content[j] == '}' || content[j] == ']') {
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// skipJSONCSpace 跳过空白和注释, 返回下一个有效字符的位置
+func skipJSONCSpace(content []byte, i int) int {
+	for i < len(content) {
+		switch {
+		case strings.IndexByte(" \t\r\n", content[i]) >= 0:
+			i++
+		case bytes.HasPrefix(content[i:], []byte("//")):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case bytes.HasPrefix(content[i:], []byte("/*")):
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(content)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// aliasPlugin 按别名解析导入路径, 找不到文件时以 esbuild 错误的形式报告, 位置为导入语句
+// 与 TypeScript 一致, 相对路径不使用别名, 以 * 开头的别名都找不到文件时交给 esbuild 按 node_modules 等规则解析
+func aliasPlugin(aliases []pathAlias) esbuild.Plugin {
+	return esbuild.Plugin{
+		Name: "alias-resolver",
+		Setup: func(build esbuild.PluginBuild) {
+			if len(aliases) == 0 {
+				return
+			}
+
+			filters := make([]string, 0, len(aliases))
+			for _, alias := range aliases {
+				filters = append(filters, alias.filter())
+			}
+
+			build.OnResolve(esbuild.OnResolveOptions{Filter: "^(?:" + strings.Join(filters, "|") + ")"}, func(args esbuild.OnResolveArgs) (esbuild.OnResolveResult, error) {
+				if isRelativeImport(args.Path) {
+					return esbuild.OnResolveResult{}, nil
+				}
+
+				for _, alias := range aliases {
+					matched, ok := alias.match(args.Path)
+					if !ok {
+						continue
+					}
+
+					var tried []string
+					for _, target := range alias.targets {
+						candidate := strings.Replace(target, "*", matched, 1)
+						if resolved, ok := resolveAliasFile(candidate); ok {
+							return esbuild.OnResolveResult{Path: resolved}, nil
+						}
+						tried = append(tried, candidate)
+					}
+					if alias.catchAll() {
+						continue
+					}
+
+					return esbuild.OnResolveResult{
+						Errors: []esbuild.Message{{
+							Text: fmt.Sprintf("Could not resolve %q with alias %q from %s", args.Path, alias.pattern, alias.source),
+							Notes: []esbuild.Note{{
+								Text: "Tried " + strings.Join(tried, ", ") + " with extensions " + strings.Join(aliasExtensions, ", ") + " and index files",
+							}},
+						}},
+					}, nil
+				}
+
+				// 只匹配了过滤条件的前缀, 或者只匹配了以 * 开头的别名, 交给 esbuild 继续解析
+				return esbuild.OnResolveResult{}, nil
+			})
+		},
+	}
+}
+
+// isRelativeImport 相对路径和绝对路径的导入
+func isRelativeImport(importPath string) bool {
+	return importPath == "." || importPath == ".." ||
+		strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") ||
+		strings.HasPrefix(importPath, "/")
+}
+
+// resolveAliasFile 依次尝试文件本身、添加扩展名和目录下的 index 文件
+func resolveAliasFile(base string) (string, bool) {
+	isFile := func(p string) bool {
+		info, err := os.Stat(p)
+		return err == nil && !info.IsDir()
+	}
+
+	if isFile(base) {
+		return base, true
+	}
+	for _, ext := range aliasExtensions {
+		if isFile(base + ext) {
+			return base + ext, true
+		}
+	}
+	for _, ext := range aliasExtensions {
+		index := filepath.Join(base, "index"+ext)
+		if isFile(index) {
+			return index, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), DefaultFileMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", `{"a": 1}`, `{"a": 1}`},
+		{"line comment", "{\"a\": 1 // one\n}", "{\"a\": 1 \n}"},
+		{"block comment", `{/* x */"a": 1}`, `{"a": 1}`},
+		{"comment in string", `{"a": "// not /* a comment */"}`, `{"a": "// not /* a comment */"}`},
+		{"escaped quote", `{"a": "\"//"}`, `{"a": "\"//"}`},
+		{"trailing comma object", `{"a": 1,}`, `{"a": 1}`},
+		{"trailing comma array", `[1, 2, ]`, `[1, 2 ]`},
+		{"trailing comma before comment", "{\"a\": 1, // x\n}", "{\"a\": 1 \n}"},
+		{"comma in string", `{"a": ",}"}`, `{"a": ",}"}`},
+		{"unterminated block", `{"a": 1 /* x`, `{"a": 1 `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripJSONC([]byte(tt.in))); got != tt.want {
+				t.Errorf("stripJSONC(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTsconfig(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"node_modules/@base/tsconfig/tsconfig.json": `{"compilerOptions": {"baseUrl": "."}}`,
+		"configs/paths.json": `{
+			// 父配置中的 paths 相对于父配置所在目录
+			"compilerOptions": {"paths": {"@shared/*": ["./shared/*"],}},
+		}`,
+		"tsconfig.json":  `{"extends": ["@base/tsconfig", "./configs/paths"]}`,
+		"override.json":  `{"extends": "./tsconfig.json", "compilerOptions": {"baseUrl": "./src", "paths": {"~/*": ["*"]}}}`,
+		"circular.json":  `{"extends": "./circular.json"}`,
+		"missing.json":   `{"extends": "./nope.json"}`,
+		"wildcards.json": `{"compilerOptions": {"paths": {"a/*/*": ["*"]}}}`,
+	})
+
+	tests := []struct {
+		file     string
+		baseURL  string
+		paths    map[string][]string
+		pathsDir string
+		err      string
+	}{
+		{
+			file:     "tsconfig.json",
+			baseURL:  filepath.Join(root, "node_modules/@base/tsconfig"),
+			paths:    map[string][]string{"@shared/*": {"./shared/*"}},
+			pathsDir: filepath.Join(root, "configs"),
+		},
+		{
+			file:     "override.json",
+			baseURL:  filepath.Join(root, "src"),
+			paths:    map[string][]string{"~/*": {"*"}},
+			pathsDir: root,
+		},
+		{file: "circular.json", err: "circular extends"},
+		{file: "missing.json", err: "cannot find extended config"},
+		{file: "wildcards.json", err: "at most one '*'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := readTsconfig(filepath.Join(root, tt.file), nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.baseURL != tt.baseURL || got.pathsDir != tt.pathsDir || !reflect.DeepEqual(got.paths, tt.paths) {
+				t.Errorf("got %+v, want baseURL=%s paths=%v pathsDir=%s", got, tt.baseURL, tt.paths, tt.pathsDir)
+			}
+		})
+	}
+}
+
+func TestTsconfigChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		extended []string
+	}{
+		{name: "no config"},
+		{
+			name: "extends",
+			files: map[string]string{
+				"node_modules/@base/tsconfig/tsconfig.json": `{}`,
+				"configs/paths.json":                        `{"extends": "@base/tsconfig"}`,
+				"tsconfig.json":                             `{"extends": "./configs/paths"}`,
+			},
+			extended: []string{"configs/paths.json", "node_modules/@base/tsconfig/tsconfig.json"},
+		},
+		{
+			name: "frontend config",
+			files: map[string]string{
+				"shared.json":            `{}`,
+				"frontend/jsconfig.json": `{"extends": "../shared.json"}`,
+			},
+			extended: []string{"shared.json"},
+		},
+		{
+			name:     "missing extends",
+			files:    map[string]string{"tsconfig.json": `{"extends": "./nope.json"}`},
+			extended: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, tt.files)
+			config := &BuildConfig{RootDir: root, FrontendDir: filepath.Join(root, "frontend")}
+
+			want := []string{
+				filepath.Join(root, "tsconfig.json"),
+				filepath.Join(root, "jsconfig.json"),
+				filepath.Join(root, "frontend", "tsconfig.json"),
+				filepath.Join(root, "frontend", "jsconfig.json"),
+			}
+			for _, name := range tt.extended {
+				want = append(want, filepath.Join(root, filepath.FromSlash(name)))
+			}
+			if got := config.tsconfigChain(); !reflect.DeepEqual(got, want) {
+				t.Errorf("tsconfigChain() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPathAliasMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path, matched string
+		ok                     bool
+	}{
+		{"@/*", "@/pages/Home", "pages/Home", true},
+		{"@/*", "@shared/x", "", false},
+		{"~ui", "~ui", "", true},
+		{"~ui", "~ui/button", "", false},
+		{"*.svg", "logo.svg", "logo", true},
+		{"*", "react", "react", true},
+	}
+
+	for _, tt := range tests {
+		matched, ok := pathAlias{pattern: tt.pattern}.match(tt.path)
+		if matched != tt.matched || ok != tt.ok {
+			t.Errorf("%q.match(%q) = (%q, %v), want (%q, %v)", tt.pattern, tt.path, matched, ok, tt.matched, tt.ok)
+		}
+	}
+}
+
+func TestMergeAliases(t *testing.T) {
+	merged := mergeAliases(
+		[]pathAlias{{pattern: "@/*", source: "config"}},
+		[]pathAlias{{pattern: "*"}, {pattern: "@/*", source: "tsconfig"}, {pattern: "@shared/*"}, {pattern: "~ui"}},
+	)
+
+	var patterns []string
+	for _, alias := range merged {
+		patterns = append(patterns, alias.pattern)
+	}
+	if want := []string{"~ui", "@shared/*", "@/*", "*"}; !reflect.DeepEqual(patterns, want) {
+		t.Errorf("patterns = %v, want %v", patterns, want)
+	}
+	if merged[2].source != "config" {
+		t.Errorf("@/* source = %q, want config", merged[2].source)
+	}
+}
+
+func TestAliasPlugin(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"node_modules/pkg/index.js": `export const pkg = "from node_modules";`,
+		"types/shim.ts":             `export const shim = "from types";`,
+		"src/local.ts":              `export const local = "relative";`,
+		"src/ui/index.ts":           `export const ui = "from alias";`,
+	})
+
+	aliases := mergeAliases([]pathAlias{
+		{pattern: "*", targets: []string{filepath.Join(root, "types/*"), filepath.Join(root, "*")}, source: "tsconfig.json"},
+		{pattern: "@ui", targets: []string{filepath.Join(root, "src/ui")}, source: "tsconfig.json"},
+		{pattern: "@missing/*", targets: []string{filepath.Join(root, "nope/*")}, source: "tsconfig.json"},
+	})
+
+	build := func(source string) esbuild.BuildResult {
+		return esbuild.Build(esbuild.BuildOptions{
+			Stdin:         &esbuild.StdinOptions{Contents: source, ResolveDir: filepath.Join(root, "src"), Loader: esbuild.LoaderTS},
+			Bundle:        true,
+			Write:         false,
+			AbsWorkingDir: root,
+			LogLevel:      esbuild.LogLevelSilent,
+			Plugins:       []esbuild.Plugin{aliasPlugin(aliases)},
+		})
+	}
+
+	result := build(`
+		import { pkg } from "pkg";
+		import { shim } from "shim";
+		import { local } from "./local";
+		import { ui } from "@ui";
+		console.log(pkg, shim, local, ui);
+	`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	output := string(result.OutputFiles[0].Contents)
+	for _, want := range []string{"from node_modules", "from types", "relative", "from alias"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	result = build(`import "@missing/thing";`)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Text, `alias "@missing/*"`) {
+		t.Errorf("errors = %v, want unresolved alias error", result.Errors)
+	}
+}
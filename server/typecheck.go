@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xlog"
+)
+
+// DefaultTypeCheckCommand 默认的类型检查命令, --no-install 避免 npx 下载同名的其他包
+var DefaultTypeCheckCommand = []string{"npx", "--no-install", "tsc", "--noEmit", "--pretty", "false"}
+
+var (
+	// src/pages/Home.tsx(12,5): error TS2322: Type 'string' is not assignable to type 'number'.
+	tscDiagnosticPattern = regexp.MustCompile(`^(.+?)\((\d+),(\d+)\): (error|warning|message) TS(\d+): (.*)$`)
+	// error TS5058: The specified path does not exist: 'tsconfig.json'.
+	tscGlobalPattern = regexp.MustCompile(`^(error|warning|message) TS(\d+): (.*)$`)
+)
+
+// TypeDiagnostic tsc 输出的一条诊断信息, File 相对于项目根目录, 全局错误没有文件位置
+type TypeDiagnostic struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Category string `json:"category"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (d TypeDiagnostic) String() string {
+	message := strings.ReplaceAll(d.Message, "\n", "\n  ")
+	if d.File == "" {
+		return fmt.Sprintf("%s TS%d: %s", d.Category, d.Code, message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s TS%d: %s", d.File, d.Line, d.Column, d.Category, d.Code, message)
+}
+
+// TypeCheckResult 一次类型检查的结果
+type TypeCheckResult struct {
+	Diagnostics []TypeDiagnostic `json:"diagnostics"`
+	Duration    time.Duration    `json:"duration"`
+	// 没有 tsconfig.json 时不检查
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Errors 错误级别的诊断数量
+func (r *TypeCheckResult) Errors() int {
+	count := 0
+	for _, d := range r.Diagnostics {
+		if d.Category == "error" {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteText 输出诊断信息, 格式与 tsc 一致, 便于编辑器和终端跳转
+func (r *TypeCheckResult) WriteText(w io.Writer) error {
+	if r.Skipped {
+		_, err := fmt.Fprintln(w, "typecheck: skipped, no tsconfig.json found")
+		return err
+	}
+	for _, d := range r.Diagnostics {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "typecheck: %d error(s) in %s\n", r.Errors(), r.Duration.Round(time.Millisecond))
+	return err
+}
+
+// TypeCheckError 开启 TypeCheckFatal 时生产构建因类型错误失败, 诊断信息在 Result 中
+type TypeCheckError struct {
+	Result *TypeCheckResult
+}
+
+func (e *TypeCheckError) Error() string {
+	return fmt.Sprintf("typecheck failed with %d error(s)", e.Result.Errors())
+}
+
+// typeCheckProject tsc 使用的 tsconfig, 优先项目根目录, 其次 frontend 目录
+func (c *BuildConfig) typeCheckProject() (string, bool) {
+	for _, dir := range []string{c.RootDir, c.FrontendDir} {
+		project := filepath.Join(dir, "tsconfig.json")
+		if _, err := os.Stat(project); err == nil {
+			return project, true
+		}
+	}
+	return "", false
+}
+
+// runTypeCheck 在项目根目录执行 tsc --noEmit 并解析诊断信息
+// tsc 有类型错误时以非 0 状态退出, 只有无法解析出诊断信息时才返回 error, 例如没有安装 typescript
+func runTypeCheck(ctx context.Context, config *BuildConfig) (*TypeCheckResult, error) {
+	start := time.Now()
+	project, ok := config.typeCheckProject()
+	if !ok {
+		xlog.Debug("typecheck skipped, no tsconfig.json found", xlog.String("root", config.RootDir))
+		return &TypeCheckResult{Skipped: true}, nil
+	}
+
+	command := config.TypeCheckCommand
+	if len(command) == 0 {
+		command = DefaultTypeCheckCommand
+	}
+	args := append(command[1:len(command):len(command)], "--project", project)
+
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Dir = config.RootDir
+	xlog.Debug("typecheck", xlog.String("cmd", cmd.String()))
+	output, runErr := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &TypeCheckResult{
+		Diagnostics: parseTypeDiagnostics(output, config.RootDir),
+		Duration:    time.Since(start),
+	}
+	if runErr != nil && len(result.Diagnostics) == 0 {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return nil, fmt.Errorf("%s: %w\n%s", cmd.String(), runErr, text)
+		}
+		return nil, fmt.Errorf("%s: %w", cmd.String(), runErr)
+	}
+	return result, nil
+}
+
+// parseTypeDiagnostics 解析 tsc --pretty false 的输出, 缩进的行是上一条诊断的补充说明
+func parseTypeDiagnostics(output []byte, rootDir string) []TypeDiagnostic {
+	var diagnostics []TypeDiagnostic
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if match := tscDiagnosticPattern.FindStringSubmatch(line); match != nil {
+			file := match[1]
+			if filepath.IsAbs(file) {
+				if rel, err := filepath.Rel(rootDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+					file = rel
+				}
+			}
+			lineNo, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			code, _ := strconv.Atoi(match[5])
+			diagnostics = append(diagnostics, TypeDiagnostic{
+				File:     filepath.ToSlash(file),
+				Line:     lineNo,
+				Column:   column,
+				Category: match[4],
+				Code:     code,
+				Message:  match[6],
+			})
+			continue
+		}
+
+		if match := tscGlobalPattern.FindStringSubmatch(line); match != nil {
+			code, _ := strconv.Atoi(match[2])
+			diagnostics = append(diagnostics, TypeDiagnostic{Category: match[1], Code: code, Message: match[3]})
+			continue
+		}
+
+		if len(diagnostics) > 0 && strings.HasPrefix(line, " ") {
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + strings.TrimSpace(line)
+		}
+	}
+	return diagnostics
+}
+
+// startTypeCheck 开启类型检查时在后台执行 tsc, 与打包并行
+// 返回的函数等待检查完成, abort 为 true 时取消检查, 用于打包已经失败的情况
+func (b *JSBuilder) startTypeCheck() func(abort bool) error {
+	b.typeCheck = nil
+	if !b.config.TypeCheck {
+		return func(bool) error { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		result, err := runTypeCheck(ctx, b.config)
+		b.typeCheck = result
+		done <- err
+	}()
+
+	return func(abort bool) error {
+		defer cancel()
+		if abort {
+			cancel()
+			<-done
+			return nil
+		}
+		return b.checkTypes(<-done)
+	}
+}
+
+// checkTypes 处理类型检查结果, 只有生产模式并开启 TypeCheckFatal 时类型错误才会使构建失败
+func (b *JSBuilder) checkTypes(err error) error {
+	fatal := b.config.TypeCheckFatal && b.config.Mode == ModeProduction
+	if err != nil {
+		if fatal {
+			return fmt.Errorf("typecheck: %w", err)
+		}
+		xlog.Warn("typecheck failed to run", xlog.Err(err))
+		return nil
+	}
+
+	logTypeCheck(b.typeCheck)
+	if fatal && b.typeCheck.Errors() > 0 {
+		return &TypeCheckError{Result: b.typeCheck}
+	}
+	return nil
+}
+
+// TypeCheck 最近一次构建的类型检查结果, 未开启或没有执行时为 nil
+func (b *JSBuilder) TypeCheck() *TypeCheckResult {
+	return b.typeCheck
+}
+
+// typeChecker dev 模式下在后台执行类型检查, 新的检查开始时取消还未完成的检查
+type typeChecker struct {
+	config *BuildConfig
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	last   *TypeCheckResult
+}
+
+func newTypeChecker(config *BuildConfig) *typeChecker {
+	return &typeChecker{config: config}
+}
+
+// Start 开始一次类型检查, 完成后调用 done, 被取消的检查不会调用
+func (t *typeChecker) Start(done func(*TypeCheckResult)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.cancel = cancel
+	t.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := runTypeCheck(ctx, t.config)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			xlog.Error("typecheck", xlog.Err(err))
+			return
+		}
+
+		t.mu.Lock()
+		t.last = result
+		t.mu.Unlock()
+		logTypeCheck(result)
+		done(result)
+	}()
+}
+
+// Last 最近一次完成的类型检查结果
+func (t *typeChecker) Last() *TypeCheckResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// typeCheckEvent 通过 HMR 通道发送给浏览器的类型检查结果
+func typeCheckEvent(result *TypeCheckResult) string {
+	data, _ := json.Marshal(struct {
+		Type string `json:"type"`
+		*TypeCheckResult
+	}{"typecheck", result})
+	return string(data)
+}
+
+func logTypeCheck(result *TypeCheckResult) {
+	if result.Skipped {
+		return
+	}
+	if errs := result.Errors(); errs > 0 {
+		diagnostics := make([]string, 0, len(result.Diagnostics))
+		for _, d := range result.Diagnostics {
+			diagnostics = append(diagnostics, d.String())
+		}
+		xlog.Warn("typecheck found errors", xlog.Int("errors", errs), xlog.String("diagnostics", strings.Join(diagnostics, "\n")))
+		return
+	}
+	xlog.Info("typecheck passed", xlog.String("duration", result.Duration.Round(time.Millisecond).String()))
+}
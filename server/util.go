@@ -15,7 +15,10 @@ import (
 var Templates embed.FS
 
 var functions template.FuncMap = template.FuncMap{
-	"convertToJson": convertToJson,
+	"convertToJson":  convertToJson,
+	"asset":          assetURL,
+	"assetCSS":       assetCSS,
+	"modulePreloads": modulePreloads,
 }
 
 func convertToJson(a any) string {
@@ -24,16 +27,15 @@ func convertToJson(a any) string {
 }
 
 type GeneralPayload struct {
-	Translations           any
 	Payload                any
+	State                  template.HTML
 	Template               string
 	TemplateID             string
 	ServerURL              string
 	Component              string
 	InnerHtmlContent       template.HTML
+	CriticalCSS            template.CSS
 	Lang                   string
-	Website                *conf.Website
-	UserInfo               any
 	GoogleAdsTxt           string
 	GoogleAdsJS            string
 	GoogleAnalytics        string
@@ -42,6 +44,13 @@ type GeneralPayload struct {
 	Version                string
 	IsDev                  bool
 	CloudflareTurnstileKey string
+
+	// Deprecated: 页面状态已经通过 State 输出, 保留用于兼容使用 {{ .Translations }} 的自定义模板
+	Translations any
+	// Deprecated: 页面状态已经通过 State 输出, 保留用于兼容使用 {{ .Website }} 的自定义模板
+	Website *conf.Website
+	// Deprecated: 页面状态已经通过 State 输出, 保留用于兼容使用 {{ .UserInfo }} 的自定义模板
+	UserInfo any
 }
 
 func extendPayload(